@@ -0,0 +1,347 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Version of the catalog structure, raised whenever a field is renamed or removed.
+const catalogVersion = 1
+
+// Typedef for the run catalog, written out as catalog.json
+type Catalog struct {
+	Version int `json:"version"`
+	Generated time.Time `json:"generated"`
+	Database CatalogDatabase `json:"database"`
+	Tables []CatalogTable `json:"tables"`
+}
+
+// Typedef for the database level catalog information
+type CatalogDatabase struct {
+	Server string `json:"server"`
+	Name string `json:"name"`
+	ServerVersion string `json:"serverVersion"`
+	Edition string `json:"edition"`
+	CompatibilityLevel int `json:"compatibilityLevel"`
+	Collation string `json:"collation"`
+}
+
+// Typedef for a catalog table
+type CatalogTable struct {
+	Name string `json:"name"`
+	Schema string `json:"schema"`
+	Kind string `json:"kind"`
+	Base string `json:"base"`
+	Description string `json:"description"`
+	RowCount int `json:"rowCount"`
+	Watermark string `json:"watermark"`
+	WatermarkStrategy string `json:"watermarkStrategy"`
+	WatermarkReason string `json:"watermarkReason"`
+	Filter string `json:"filter"`
+	Type2 bool `json:"type2"`
+	Columns []CatalogColumn `json:"columns"`
+	PrimaryKey *CatalogKey `json:"primaryKey"`
+	UniqueConstraints []CatalogKey `json:"uniqueConstraints"`
+	ForeignKeys []CatalogForeignKey `json:"foreignKeys"`
+	CheckConstraints []CatalogCheck `json:"checkConstraints"`
+	Indexes []CatalogIndex `json:"indexes"`
+	Files CatalogFiles `json:"files"`
+}
+
+// Typedef for a catalog column, lengths are in bytes as reported by sys.columns
+type CatalogColumn struct {
+	Name string `json:"name"`
+	DataType string `json:"dataType"`
+	MaxLength int `json:"maxLength"`
+	Precision int `json:"precision"`
+	Scale int `json:"scale"`
+	Nullable bool `json:"nullable"`
+	Ordinal int `json:"ordinal"`
+	Collation string `json:"collation"`
+	PrimaryKey bool `json:"primaryKey"`
+	Default string `json:"default"`
+	DefaultName string `json:"defaultName"`
+	Identity bool `json:"identity"`
+	IdentitySeed string `json:"identitySeed"`
+	IdentityIncrement string `json:"identityIncrement"`
+	Computed string `json:"computed"`
+	Persisted bool `json:"persisted"`
+	Description string `json:"description"`
+	LogicalName string `json:"logicalName"`
+	Structure string `json:"structure"`
+	Array bool `json:"array"`
+}
+
+// Typedef for a catalog primary key or unique constraint
+type CatalogKey struct {
+	Name string `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// Typedef for a catalog foreign key
+type CatalogForeignKey struct {
+	Name string `json:"name"`
+	Columns []string `json:"columns"`
+	ReferencedTable string `json:"referencedTable"`
+	ReferencedColumns []string `json:"referencedColumns"`
+	DeleteAction string `json:"deleteAction"`
+	UpdateAction string `json:"updateAction"`
+}
+
+// Typedef for a catalog check constraint
+type CatalogCheck struct {
+	Name string `json:"name"`
+	Column string `json:"column"`
+	Definition string `json:"definition"`
+}
+
+// Typedef for a catalog index
+type CatalogIndex struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Clustered bool `json:"clustered"`
+	Unique bool `json:"unique"`
+	PrimaryKey bool `json:"primaryKey"`
+	KeyColumns []string `json:"keyColumns"`
+	DescendingColumns []string `json:"descendingColumns"`
+	IncludedColumns []string `json:"includedColumns"`
+	Filter string `json:"filter"`
+}
+
+// Typedef for the files written for a table, relative to the run folder
+type CatalogFiles struct {
+	Metadata string `json:"metadata"`
+	Constraints string `json:"constraints"`
+	Indexes string `json:"indexes"`
+	Describe string `json:"describe"`
+	Targets map[string]string `json:"targets"`
+	JSONSchema string `json:"jsonSchema"`
+	AvroSchema string `json:"avroSchema"`
+	SparkSchema string `json:"sparkSchema"`
+	Data string `json:"data"`
+}
+
+// Load a catalog written by a previous run.
+func loadCatalog(path string) (*Catalog, error) {
+	catalogFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var catalog Catalog
+	err = json.Unmarshal(catalogFile, &catalog)
+	if err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+func buildCatalog(config *Config, info DatabaseInfo, tables []Table, targets []Dialect, extract bool) Catalog {
+
+	catalog := Catalog{
+		Version: catalogVersion,
+		Generated: time.Now(),
+		Database: CatalogDatabase{
+			Server: config.Server,
+			Name: config.Database,
+			ServerVersion: info.serverVersion,
+			Edition: info.edition,
+			CompatibilityLevel: info.compatibilityLevel,
+			Collation: info.collation,
+		},
+		Tables: make([]CatalogTable, 0),
+	}
+
+	for _, table := range tables {
+		catalog.Tables = append(catalog.Tables, catalogTable(table, targets, extract))
+	}
+
+	return catalog
+}
+
+func catalogTable(table Table, targets []Dialect, extract bool) CatalogTable {
+
+	result := CatalogTable{
+		Name: table.name,
+		Schema: table.schema,
+		Kind: table.kind,
+		Base: table.base,
+		Description: table.description,
+		RowCount: table.rowCount,
+		Watermark: table.timestamp,
+		WatermarkStrategy: table.watermarkStrategy,
+		WatermarkReason: table.watermarkReason,
+		Filter: table.filter,
+		Type2: table.type2,
+		Columns: make([]CatalogColumn, 0),
+		UniqueConstraints: make([]CatalogKey, 0),
+		ForeignKeys: make([]CatalogForeignKey, 0),
+		CheckConstraints: make([]CatalogCheck, 0),
+		Indexes: make([]CatalogIndex, 0),
+		Files: catalogFiles(table, targets, extract),
+	}
+
+	for _, column := range table.columns {
+		result.Columns = append(result.Columns, catalogColumn(column))
+	}
+
+	if len(table.primaryKey.columns) > 0 {
+		result.PrimaryKey = &CatalogKey{Name: table.primaryKey.name, Columns: make([]string, 0)}
+		for _, column := range table.primaryKey.columns {
+			result.PrimaryKey.Columns = append(result.PrimaryKey.Columns, column.name)
+		}
+	}
+
+	for _, unique := range table.uniqueConstraints {
+		result.UniqueConstraints = append(result.UniqueConstraints, CatalogKey{Name: unique.name, Columns: unique.columns})
+	}
+
+	for _, foreignKey := range table.foreignKeys {
+		result.ForeignKeys = append(result.ForeignKeys, CatalogForeignKey{
+			Name: foreignKey.name,
+			Columns: foreignKey.columns,
+			ReferencedTable: foreignKey.referencedTable,
+			ReferencedColumns: foreignKey.referencedColumns,
+			DeleteAction: foreignKey.deleteAction,
+			UpdateAction: foreignKey.updateAction,
+		})
+	}
+
+	for _, check := range table.checkConstraints {
+		result.CheckConstraints = append(result.CheckConstraints, CatalogCheck{
+			Name: check.name,
+			Column: check.column,
+			Definition: check.definition,
+		})
+	}
+
+	for _, index := range table.indexes {
+		catalogIndex := CatalogIndex{
+			Name: index.name,
+			Type: index.indexType,
+			Clustered: index.clustered,
+			Unique: index.unique,
+			PrimaryKey: index.primaryKey,
+			KeyColumns: make([]string, 0),
+			DescendingColumns: make([]string, 0),
+			IncludedColumns: make([]string, 0),
+			Filter: index.filterDefinition,
+		}
+		for _, column := range index.keyColumns {
+			catalogIndex.KeyColumns = append(catalogIndex.KeyColumns, column.name)
+			if column.descending {
+				catalogIndex.DescendingColumns = append(catalogIndex.DescendingColumns, column.name)
+			}
+		}
+		catalogIndex.IncludedColumns = append(catalogIndex.IncludedColumns, index.includedColumns...)
+		result.Indexes = append(result.Indexes, catalogIndex)
+	}
+
+	return result
+}
+
+func catalogColumn(column Column) CatalogColumn {
+	maxLength, _ := strconv.Atoi(column.maxLength.String)
+	precision, _ := strconv.Atoi(column.precision.String)
+	scale, _ := strconv.Atoi(column.scale.String)
+	ordinal, _ := strconv.Atoi(column.ordinalPosition.String)
+
+	return CatalogColumn{
+		Name: column.name.String,
+		DataType: column.dataType.String,
+		MaxLength: maxLength,
+		Precision: precision,
+		Scale: scale,
+		Nullable: column.nullable.String != "false",
+		Ordinal: ordinal,
+		Collation: column.collationName.String,
+		PrimaryKey: column.primaryKey.String == "true",
+		Default: column.defaultDefinition.String,
+		DefaultName: column.defaultName.String,
+		Identity: column.identity.String == "true",
+		IdentitySeed: column.identitySeed.String,
+		IdentityIncrement: column.identityIncrement.String,
+		Computed: column.computedDefinition.String,
+		Persisted: column.persisted.String == "true",
+		Description: column.description.String,
+		LogicalName: column.logicalName.String,
+		Structure: column.structure.String,
+		Array: column.array.String == "true",
+	}
+}
+
+// The files a run writes for a table, relative to the run folder.
+func catalogFiles(table Table, targets []Dialect, extract bool) CatalogFiles {
+	sep := string(filepath.Separator)
+
+	files := CatalogFiles{
+		Metadata: "metadata" + sep + table.name + ".csv",
+		Constraints: "metadata" + sep + table.name + "_constraints.csv",
+		Indexes: "metadata" + sep + table.name + "_indexes.csv",
+		Describe: "describe" + sep + table.name + ".sql",
+		Targets: make(map[string]string),
+		JSONSchema: "schemas" + sep + table.name + ".schema.json",
+		AvroSchema: "schemas" + sep + table.name + ".avsc",
+		SparkSchema: "schemas" + sep + table.name + ".spark.json",
+	}
+
+	for _, dialect := range targets {
+		files.Targets[dialect.name] = "describe" + sep + dialect.name + sep + table.name + ".sql"
+	}
+
+	// Queries and entities have no constraints or indexes of their own.
+	switch table.kind {
+		case "query":
+			files.Constraints, files.Indexes = "", ""
+			if extract {
+				files.Data = "queries" + sep + dataFile(table)
+			}
+			return files
+		case "entity":
+			files.Constraints, files.Indexes = "", ""
+			if extract && table.rowCount > 0 {
+				files.Data = "entities" + sep + dataFile(table)
+			}
+			return files
+	}
+
+	// Only tables with rows are downloaded.
+	if extract && table.rowCount > 0 {
+		files.Data = "tables" + sep + dataFile(table)
+	}
+
+	return files
+}
+
+// Turn a catalog column back into the column model, so it can be rendered like live metadata.
+func (column CatalogColumn) column() Column {
+	text := func(value string) sql.NullString {
+		return sql.NullString{String: value, Valid: true}
+	}
+	return Column{
+		name: text(column.Name),
+		dataType: text(column.DataType),
+		maxLength: text(strconv.Itoa(column.MaxLength)),
+		precision: text(strconv.Itoa(column.Precision)),
+		scale: text(strconv.Itoa(column.Scale)),
+		nullable: text(strconv.FormatBool(column.Nullable)),
+		ordinalPosition: text(strconv.Itoa(column.Ordinal)),
+		collationName: sql.NullString{String: column.Collation, Valid: column.Collation != ""},
+		primaryKey: text(strconv.FormatBool(column.PrimaryKey)),
+		defaultName: sql.NullString{String: column.DefaultName, Valid: column.DefaultName != ""},
+		defaultDefinition: sql.NullString{String: column.Default, Valid: column.Default != ""},
+		identity: text(strconv.FormatBool(column.Identity)),
+		identitySeed: text(column.IdentitySeed),
+		identityIncrement: text(column.IdentityIncrement),
+		computed: text(strconv.FormatBool(column.Computed != "")),
+		computedDefinition: sql.NullString{String: column.Computed, Valid: column.Computed != ""},
+		persisted: text(strconv.FormatBool(column.Persisted)),
+		description: sql.NullString{String: column.Description, Valid: column.Description != ""},
+		logicalName: sql.NullString{String: column.LogicalName, Valid: column.LogicalName != ""},
+		structure: sql.NullString{String: column.Structure, Valid: column.Structure != ""},
+		array: sql.NullString{String: strconv.FormatBool(column.Array), Valid: column.Array},
+	}
+}
\ No newline at end of file
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Typedef for a table definition, rendered out as a describe script
+type TableDefinition struct {
+	name string
+	comments []string
+	columns []string
+	columnComments []string
+	constraints []string
+	statements []string
+}
+
+// Render the definition as a script which can be run as is.
+func (definition TableDefinition) String() string {
+
+	// Columns come first, followed by the table level constraints.
+	lines := make([]string, 0)
+	for index, column := range definition.columns {
+
+		// Column comments sit on their own line above the column.
+		if index < len(definition.columnComments) && definition.columnComments[index] != "" {
+			column = "-- " + definition.columnComments[index] + "\n\t" + column
+		}
+		lines = append(lines, "\t" + column)
+	}
+	for _, constraint := range definition.constraints {
+		lines = append(lines, "\t" + constraint)
+	}
+
+	// Comments are written ahead of the table.
+	var script string
+	for _, comment := range definition.comments {
+		script += "-- " + comment + "\n"
+	}
+
+	script += fmt.Sprintf("CREATE TABLE %s (\n%s\n);\n", definition.name, strings.Join(lines, ",\n"))
+
+	// Any statements which must run after the table exists.
+	for _, statement := range definition.statements {
+		script += "\n" + statement + "\n"
+	}
+
+	return script
+}
+
+func writeDescribes(tables []Table, folder string) {
+	for _, table := range tables {
+
+		// Create the SQL file handle.
+		outFile, err := os.Create(folder + string(filepath.Separator) + table.name + ".sql")
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		// Views, synonyms and functions are described by their own definition.
+		if table.definition != "" {
+			outFile.WriteString(table.definition)
+		} else {
+			outFile.WriteString(sqlServerDefinition(table).String())
+		}
+		outFile.Close()
+	}
+}
+
+// Build the SQL Server definition of a table.
+func sqlServerDefinition(table Table) TableDefinition {
+
+	definition := TableDefinition{name: scriptName(table.name)}
+
+	if table.description != "" {
+		definition.comments = append(definition.comments, singleLine(table.description))
+	}
+
+	for _, column := range table.columns {
+		definition.columnComments = append(definition.columnComments, singleLine(column.description.String))
+
+		// Computed columns only carry their expression.
+		if column.computed.String == "true" {
+			var persisted string
+			if column.persisted.String == "true" {
+				persisted = " PERSISTED"
+			}
+			definition.columns = append(definition.columns, fmt.Sprintf("%s AS %s%s",
+				quoteName(column.name.String),
+				column.computedDefinition.String,
+				persisted,
+			))
+			continue
+		}
+
+		var null string
+		switch column.nullable.String {
+			case "true":
+				null = "NULL"
+			case "false":
+				null = "NOT NULL"
+			default:
+				null = "NULL"
+		}
+
+		var identity string
+		if column.identity.String == "true" {
+			identity = fmt.Sprintf(" IDENTITY(%s, %s)", column.identitySeed.String, column.identityIncrement.String)
+		}
+
+		var defaultValue string
+		if column.defaultDefinition.Valid {
+			defaultValue = fmt.Sprintf(" CONSTRAINT %s DEFAULT %s", quoteName(column.defaultName.String), column.defaultDefinition.String)
+		}
+
+		definition.columns = append(definition.columns, fmt.Sprintf("%s %s%s %s%s",
+			quoteName(column.name.String),
+			sqlServerType(column),
+			identity,
+			null,
+			defaultValue,
+		))
+	}
+
+	// A single constraint covers every column of the key, in key order.
+	if len(table.primaryKey.columns) > 0 {
+		clustered := "NONCLUSTERED"
+		if table.primaryKey.clustered {
+			clustered = "CLUSTERED"
+		}
+		definition.constraints = append(definition.constraints, fmt.Sprintf("CONSTRAINT %s PRIMARY KEY %s (%s)",
+			quoteName(table.primaryKey.name),
+			clustered,
+			indexColumnList(table.primaryKey.columns),
+		))
+	}
+	for _, unique := range table.uniqueConstraints {
+		definition.constraints = append(definition.constraints, fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)",
+			quoteName(unique.name),
+			bracketList(unique.columns),
+		))
+	}
+	for _, check := range table.checkConstraints {
+		definition.constraints = append(definition.constraints, fmt.Sprintf("CONSTRAINT %s CHECK %s",
+			quoteName(check.name),
+			check.definition,
+		))
+	}
+	for _, foreignKey := range table.foreignKeys {
+		definition.constraints = append(definition.constraints, fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON DELETE %s ON UPDATE %s",
+			quoteName(foreignKey.name),
+			bracketList(foreignKey.columns),
+			scriptName(foreignKey.referencedTable),
+			bracketList(foreignKey.referencedColumns),
+			strings.Replace(foreignKey.deleteAction, "_", " ", -1),
+			strings.Replace(foreignKey.updateAction, "_", " ", -1),
+		))
+	}
+
+	// Indexes which are not already declared by a constraint.
+	for _, index := range table.indexes {
+		if index.primaryKey || index.uniqueConstraint {
+			continue
+		}
+		definition.statements = append(definition.statements, createIndexStatement(scriptName(table.name), index))
+	}
+
+	return definition
+}
+
+// Collapse a description onto one line so it can be written as a -- comment.
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Bracket quote each name and join them into a column list.
+func bracketList(names []string) string {
+	quoted := make([]string, 0)
+	for _, name := range names {
+		quoted = append(quoted, quoteName(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Build the CREATE INDEX statement for a single index.
+func createIndexStatement(tableName string, index Index) string {
+
+	var unique string
+	if index.unique {
+		unique = "UNIQUE "
+	}
+
+	switch index.indexType {
+		case "CLUSTERED", "NONCLUSTERED":
+		case "CLUSTERED COLUMNSTORE":
+			return fmt.Sprintf("CREATE CLUSTERED COLUMNSTORE INDEX %s ON %s;", quoteName(index.name), tableName)
+		case "NONCLUSTERED COLUMNSTORE":
+			return fmt.Sprintf("CREATE NONCLUSTERED COLUMNSTORE INDEX %s ON %s (%s);",
+				quoteName(index.name),
+				tableName,
+				bracketList(index.includedColumns),
+			)
+		default:
+			return fmt.Sprintf("-- Index %s of type %s is not scripted", quoteName(index.name), index.indexType)
+	}
+
+	statement := fmt.Sprintf("CREATE %s%s INDEX %s ON %s (%s)",
+		unique,
+		index.indexType,
+		quoteName(index.name),
+		tableName,
+		indexColumnList(index.keyColumns),
+	)
+	if len(index.includedColumns) > 0 {
+		statement += fmt.Sprintf(" INCLUDE (%s)", bracketList(index.includedColumns))
+	}
+	if index.filterDefinition != "" {
+		statement += fmt.Sprintf(" WHERE %s", index.filterDefinition)
+	}
+
+	return statement + ";"
+}
+
+// Bracket quote each key column and its sort direction.
+func indexColumnList(columns []IndexColumn) string {
+	quoted := make([]string, 0)
+	for _, column := range columns {
+		if column.descending {
+			quoted = append(quoted, quoteName(column.name) + " DESC")
+		} else {
+			quoted = append(quoted, quoteName(column.name))
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
\ No newline at end of file
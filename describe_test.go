@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Build a column with the metadata strings the catalog query would return.
+func testColumn(name string, dataType string, maxLength string, precision string, scale string, nullable string) Column {
+	return Column{
+		name: sql.NullString{String: name, Valid: true},
+		dataType: sql.NullString{String: dataType, Valid: true},
+		maxLength: sql.NullString{String: maxLength, Valid: true},
+		precision: sql.NullString{String: precision, Valid: true},
+		scale: sql.NullString{String: scale, Valid: true},
+		nullable: sql.NullString{String: nullable, Valid: true},
+	}
+}
+
+func TestCompositePrimaryKeyDescribe(t *testing.T) {
+	table := Table{
+		name: "ASSIGNMENTA1",
+		columns: []Column{
+			testColumn("NAME", "varchar", "60", "0", "0", "false"),
+			testColumn("RECORD_NUMBER", "int", "4", "10", "0", "false"),
+			testColumn("MEMBERS", "varchar", "60", "0", "0", "true"),
+		},
+		primaryKey: PrimaryKey{
+			name: "ASSIGNMENTA1_P",
+			clustered: true,
+			columns: []IndexColumn{{name: "NAME"}, {name: "RECORD_NUMBER"}},
+		},
+	}
+
+	script := sqlServerDefinition(table).String()
+
+	if strings.Count(script, "PRIMARY KEY") != 1 {
+		t.Fatal("Expected a single primary key clause", script)
+	}
+	if !strings.Contains(script, "CONSTRAINT [ASSIGNMENTA1_P] PRIMARY KEY CLUSTERED ([NAME], [RECORD_NUMBER])") {
+		t.Fatal("Primary key constraint is missing its key columns", script)
+	}
+	if strings.Contains(script, ",\n);") {
+		t.Fatal("Trailing comma before the closing bracket", script)
+	}
+
+	definition, _ := dialects["postgres"].definition(table)
+	if !strings.Contains(definition.String(), `CONSTRAINT "ASSIGNMENTA1_P" PRIMARY KEY ("NAME", "RECORD_NUMBER")`) {
+		t.Fatal("Postgres primary key should keep its name", definition.String())
+	}
+	definition, _ = dialects["bigquery"].definition(table)
+	if script = definition.String(); strings.Contains(script, "CONSTRAINT") || !strings.Contains(script, "PRIMARY KEY (`NAME`, `RECORD_NUMBER`) NOT ENFORCED") {
+		t.Fatal("BigQuery primary key should not be named", script)
+	}
+}
+
+func TestSqlServerTypes(t *testing.T) {
+	tests := []struct {
+		column Column
+		expected string
+	}{
+		{testColumn("A", "nvarchar", "100", "0", "0", "true"), "[nvarchar](50)"},
+		{testColumn("B", "nchar", "20", "0", "0", "true"), "[nchar](10)"},
+		{testColumn("C", "varchar", "-1", "0", "0", "true"), "[varchar](MAX)"},
+		{testColumn("D", "nvarchar", "-1", "0", "0", "true"), "[nvarchar](MAX)"},
+		{testColumn("E", "varbinary", "-1", "0", "0", "true"), "[varbinary](MAX)"},
+		{testColumn("F", "varchar", "60", "0", "0", "true"), "[varchar](60)"},
+		{testColumn("G", "time", "5", "16", "7", "true"), "[time](7)"},
+		{testColumn("H", "datetime2", "7", "23", "3", "true"), "[datetime2](3)"},
+		{testColumn("I", "datetimeoffset", "10", "34", "7", "true"), "[datetimeoffset](7)"},
+		{testColumn("J", "decimal", "9", "18", "4", "true"), "[decimal](18, 4)"},
+		{testColumn("K", "datetime", "8", "23", "3", "true"), "[datetime]"},
+		{testColumn("L", "float", "8", "53", "0", "true"), "[float]"},
+	}
+
+	for _, test := range tests {
+		result := sqlServerType(test.column)
+		if result != test.expected {
+			t.Error("Rendered", test.column.dataType.String, test.column.maxLength.String, "as", result, "expected", test.expected)
+		}
+	}
+}
+
+func TestTargetDialectDescribe(t *testing.T) {
+	table := Table{
+		name: "LOCM1",
+		columns: []Column{
+			testColumn("LOCATION", "nvarchar", "120", "0", "0", "false"),
+			testColumn("NOTES", "nvarchar", "-1", "0", "0", "true"),
+			testColumn("POSITION", "geometry", "-1", "0", "0", "true"),
+		},
+	}
+
+	definition, unmapped := dialects["postgres"].definition(table)
+	script := definition.String()
+
+	if !strings.Contains(script, `"LOCATION" varchar(60) NOT NULL`) || !strings.Contains(script, `"NOTES" text`) {
+		t.Fatal("Columns were not mapped to postgres types", script)
+	}
+	if len(unmapped) != 1 || unmapped[0].name.String != "POSITION" {
+		t.Fatal("The geometry column was not reported as unmapped", unmapped)
+	}
+	if strings.Contains(script, "[geometry]") || !strings.Contains(script, "-- Column POSITION of type geometry") {
+		t.Fatal("The unmapped column was not left out of the script", script)
+	}
+
+	// Tables outside dbo quote their schema and name separately.
+	table = Table{
+		name: "sales.Orders",
+		schema: "sales",
+		description: "Orders",
+		columns: []Column{testColumn("CUSTOMER", "int", "4", "10", "0", "false")},
+		foreignKeys: []ForeignKey{{name: "FK_Orders_Customers", columns: []string{"CUSTOMER"}, referencedTable: "sales.Customers", referencedColumns: []string{"ID"}}},
+		indexes: []Index{{name: "IX_Orders_Customer", indexType: "NONCLUSTERED", keyColumns: []IndexColumn{{name: "CUSTOMER"}}}},
+	}
+	definition, _ = dialects["postgres"].definition(table)
+	script = definition.String()
+	for _, expected := range []string{
+		`CREATE TABLE "sales"."Orders" (`,
+		`COMMENT ON TABLE "sales"."Orders" IS 'Orders';`,
+		`REFERENCES "sales"."Customers" ("ID")`,
+		`CREATE INDEX "IX_Orders_Customer" ON "sales"."Orders" ("CUSTOMER");`,
+	} {
+		if !strings.Contains(script, expected) {
+			t.Fatal("Postgres describe is missing", expected, script)
+		}
+	}
+	definition, _ = dialects["hive"].definition(table)
+	if !strings.Contains(definition.String(), "CREATE TABLE `sales`.`Orders` (") {
+		t.Fatal("Hive describe should quote the schema separately", definition.String())
+	}
+}
+
+func TestTableSchemas(t *testing.T) {
+	table := Table{
+		name: "PROBSUMMARYM1",
+		columns: []Column{
+			testColumn("NUMBER", "varchar", "60", "0", "0", "false"),
+			testColumn("OPEN-TIME", "datetime", "8", "23", "3", "true"),
+			testColumn("SEVERITY", "decimal", "9", "18", "0", "true"),
+		},
+	}
+
+	content, err := json.Marshal(tableJSONSchema(table))
+	if err != nil {
+		t.Fatal("Could not marshal the JSON Schema", err)
+	}
+	if !strings.Contains(string(content), `"properties":{"NUMBER":{"maxLength":60,"type":"string"},"OPEN-TIME"`) {
+		t.Fatal("JSON Schema properties are not in column order", string(content))
+	}
+	if !strings.Contains(string(content), `"required":["NUMBER"]`) {
+		t.Fatal("JSON Schema required columns are wrong", string(content))
+	}
+
+	avro := tableAvroSchema(table, "P_ServiceManagerSS")
+	if avro.Fields[1].Name != "OPEN_TIME" || avro.Fields[1].Aliases[0] != "OPEN-TIME" {
+		t.Fatal("Avro field name was not sanitised", avro.Fields[1])
+	}
+	if _, ok := avro.Fields[1].Type.([]interface{}); !ok || avro.Fields[1].Default == nil {
+		t.Fatal("Nullable Avro field is not a union with a null default", avro.Fields[1])
+	}
+
+	spark := tableSparkSchema(table)
+	if spark.Fields[2].Type != "decimal(18,0)" || spark.Fields[0].Nullable {
+		t.Fatal("Spark fields were not mapped", spark.Fields)
+	}
+}
+
+func TestDescriptionComments(t *testing.T) {
+	column := testColumn("NUMBER", "varchar", "60", "0", "0", "false")
+	column.description = sql.NullString{String: "Incident number,\nassigned on open", Valid: true}
+	table := Table{
+		name: "PROBSUMMARYM1",
+		description: "Incident's summary",
+		columns: []Column{column},
+	}
+
+	script := sqlServerDefinition(table).String()
+	if !strings.HasPrefix(script, "-- Incident's summary\n") || !strings.Contains(script, "\t-- Incident number, assigned on open\n\t[NUMBER]") {
+		t.Fatal("SQL Server describe is missing the description comments", script)
+	}
+
+	definition, _ := dialects["postgres"].definition(table)
+	script = definition.String()
+	if !strings.Contains(script, `COMMENT ON TABLE "PROBSUMMARYM1" IS 'Incident''s summary';`) || !strings.Contains(script, `COMMENT ON COLUMN "PROBSUMMARYM1"."NUMBER" IS 'Incident number,`) {
+		t.Fatal("Postgres describe is missing the COMMENT ON statements", script)
+	}
+
+	definition, _ = dialects["bigquery"].definition(table)
+	script = definition.String()
+	if !strings.Contains(script, "`NUMBER` STRING NOT NULL OPTIONS(description=\"Incident number,\\nassigned on open\")") {
+		t.Fatal("BigQuery describe is missing the column description", script)
+	}
+}
+
+func TestViewDescribe(t *testing.T) {
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	view := Table{
+		name: "OPENINCIDENTS",
+		schema: "dbo",
+		kind: "view",
+		definition: "CREATE VIEW OPENINCIDENTS AS SELECT NUMBER FROM INCIDENTSM1 WHERE OPEN = 't'\n",
+		columns: []Column{testColumn("NUMBER", "varchar", "60", "0", "0", "false")},
+	}
+	writeDescribes([]Table{view}, directory)
+
+	script, err := ioutil.ReadFile(filepath.Join(directory, "OPENINCIDENTS.sql"))
+	if err != nil || string(script) != view.definition {
+		t.Fatal("A view should be described by its own definition", string(script), err)
+	}
+
+	// Targets still get a table to land the view in.
+	definition, _ := dialects["postgres"].definition(view)
+	if !strings.Contains(definition.String(), `CREATE TABLE "OPENINCIDENTS"`) {
+		t.Fatal("The target describe should be a table", definition.String())
+	}
+}
+
+func TestQuotedDescribe(t *testing.T) {
+	table := Table{
+		name: "Order Lines]",
+		columns: []Column{
+			testColumn("Unit [Price]", "money", "8", "19", "4", "false"),
+			testColumn("it's", "int", "4", "10", "0", "true"),
+		},
+		primaryKey: PrimaryKey{name: "PK]Order", columns: []IndexColumn{{name: "Unit [Price]", descending: true}}},
+		indexes: []Index{{name: "IX it's", indexType: "NONCLUSTERED", keyColumns: []IndexColumn{{name: "it's"}}, includedColumns: []string{"Unit [Price]"}}},
+	}
+
+	script := sqlServerDefinition(table).String()
+	for _, expected := range []string{
+		"CREATE TABLE [Order Lines]]] (",
+		"[Unit [Price]]] [money] NOT NULL",
+		"CONSTRAINT [PK]]Order] PRIMARY KEY NONCLUSTERED ([Unit [Price]]] DESC)",
+		"CREATE NONCLUSTERED INDEX [IX it's] ON [Order Lines]]] ([it's]) INCLUDE ([Unit [Price]]]);",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Fatal("Describe is missing", expected, script)
+		}
+	}
+}
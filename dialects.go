@@ -0,0 +1,530 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Renders a source column as a type in the target dialect.
+type TypeMapping func(column Column) string
+
+// Typedef for target dialects
+type Dialect struct {
+	name string
+	quote func(name string) string
+	literal func(value string) string
+	comments string
+	types map[string]TypeMapping
+	notNull bool
+	primaryKey string
+	namedKey bool
+	constraints bool
+	indexes bool
+}
+
+// Registry of the target dialects describe scripts can be generated for.
+var dialects = map[string]Dialect{
+	"postgres": {
+		name: "postgres",
+		quote: doubleQuote,
+		literal: standardLiteral,
+		comments: "statement",
+		types: postgresTypes,
+		notNull: true,
+		primaryKey: "PRIMARY KEY (%s)",
+		namedKey: true,
+		constraints: true,
+		indexes: true,
+	},
+	"snowflake": {
+		name: "snowflake",
+		quote: doubleQuote,
+		literal: snowflakeLiteral,
+		comments: "statement",
+		types: snowflakeTypes,
+		notNull: true,
+		primaryKey: "PRIMARY KEY (%s)",
+		namedKey: true,
+		constraints: true,
+	},
+	"hive": {
+		name: "hive",
+		quote: backtickQuote,
+		literal: hiveLiteral,
+		comments: "hive",
+		types: hiveTypes,
+	},
+	"bigquery": {
+		name: "bigquery",
+		quote: bigQueryQuote,
+		literal: bigQueryLiteral,
+		comments: "bigquery",
+		types: bigQueryTypes,
+		notNull: true,
+		primaryKey: "PRIMARY KEY (%s) NOT ENFORCED",
+	},
+}
+
+var postgresTypes = map[string]TypeMapping{
+	"bigint": fixedType("bigint"),
+	"binary": fixedType("bytea"),
+	"bit": fixedType("boolean"),
+	"char": lengthType("char", "text"),
+	"date": fixedType("date"),
+	"datetime": fixedType("timestamp(3)"),
+	"datetime2": scaleType("timestamp", 6),
+	"datetimeoffset": scaleType("timestamptz", 6),
+	"decimal": decimalType("numeric"),
+	"float": floatType("real", "double precision"),
+	"image": fixedType("bytea"),
+	"int": fixedType("integer"),
+	"money": fixedType("numeric(19, 4)"),
+	"nchar": lengthType("char", "text"),
+	"ntext": fixedType("text"),
+	"numeric": decimalType("numeric"),
+	"nvarchar": lengthType("varchar", "text"),
+	"real": fixedType("real"),
+	"smalldatetime": fixedType("timestamp(0)"),
+	"smallint": fixedType("smallint"),
+	"smallmoney": fixedType("numeric(10, 4)"),
+	"text": fixedType("text"),
+	"time": scaleType("time", 6),
+	"timestamp": fixedType("bytea"),
+	"tinyint": fixedType("smallint"),
+	"uniqueidentifier": fixedType("uuid"),
+	"varbinary": fixedType("bytea"),
+	"varchar": lengthType("varchar", "text"),
+	"xml": fixedType("xml"),
+}
+
+var snowflakeTypes = map[string]TypeMapping{
+	"bigint": fixedType("NUMBER(19, 0)"),
+	"binary": lengthType("BINARY", "BINARY"),
+	"bit": fixedType("BOOLEAN"),
+	"char": lengthType("CHAR", "VARCHAR"),
+	"date": fixedType("DATE"),
+	"datetime": fixedType("TIMESTAMP_NTZ(3)"),
+	"datetime2": scaleType("TIMESTAMP_NTZ", 9),
+	"datetimeoffset": scaleType("TIMESTAMP_TZ", 9),
+	"decimal": decimalType("NUMBER"),
+	"float": fixedType("FLOAT"),
+	"geography": fixedType("GEOGRAPHY"),
+	"geometry": fixedType("GEOMETRY"),
+	"image": fixedType("BINARY"),
+	"int": fixedType("NUMBER(10, 0)"),
+	"money": fixedType("NUMBER(19, 4)"),
+	"nchar": lengthType("CHAR", "VARCHAR"),
+	"ntext": fixedType("VARCHAR"),
+	"numeric": decimalType("NUMBER"),
+	"nvarchar": lengthType("VARCHAR", "VARCHAR"),
+	"real": fixedType("FLOAT"),
+	"smalldatetime": fixedType("TIMESTAMP_NTZ(0)"),
+	"smallint": fixedType("NUMBER(5, 0)"),
+	"smallmoney": fixedType("NUMBER(10, 4)"),
+	"sql_variant": fixedType("VARIANT"),
+	"text": fixedType("VARCHAR"),
+	"time": scaleType("TIME", 9),
+	"timestamp": fixedType("BINARY(8)"),
+	"tinyint": fixedType("NUMBER(3, 0)"),
+	"uniqueidentifier": fixedType("VARCHAR(36)"),
+	"varbinary": lengthType("BINARY", "BINARY"),
+	"varchar": lengthType("VARCHAR", "VARCHAR"),
+	"xml": fixedType("VARCHAR"),
+}
+
+var hiveTypes = map[string]TypeMapping{
+	"bigint": fixedType("BIGINT"),
+	"binary": fixedType("BINARY"),
+	"bit": fixedType("BOOLEAN"),
+	"char": fixedType("STRING"),
+	"date": fixedType("DATE"),
+	"datetime": fixedType("TIMESTAMP"),
+	"datetime2": fixedType("TIMESTAMP"),
+	"datetimeoffset": fixedType("TIMESTAMP"),
+	"decimal": decimalType("DECIMAL"),
+	"float": floatType("FLOAT", "DOUBLE"),
+	"image": fixedType("BINARY"),
+	"int": fixedType("INT"),
+	"money": fixedType("DECIMAL(19, 4)"),
+	"nchar": fixedType("STRING"),
+	"ntext": fixedType("STRING"),
+	"numeric": decimalType("DECIMAL"),
+	"nvarchar": fixedType("STRING"),
+	"real": fixedType("FLOAT"),
+	"smalldatetime": fixedType("TIMESTAMP"),
+	"smallint": fixedType("SMALLINT"),
+	"smallmoney": fixedType("DECIMAL(10, 4)"),
+	"text": fixedType("STRING"),
+	"time": fixedType("STRING"),
+	"timestamp": fixedType("BINARY"),
+	"tinyint": fixedType("SMALLINT"),
+	"uniqueidentifier": fixedType("STRING"),
+	"varbinary": fixedType("BINARY"),
+	"varchar": fixedType("STRING"),
+	"xml": fixedType("STRING"),
+}
+
+var bigQueryTypes = map[string]TypeMapping{
+	"bigint": fixedType("INT64"),
+	"binary": fixedType("BYTES"),
+	"bit": fixedType("BOOL"),
+	"char": fixedType("STRING"),
+	"date": fixedType("DATE"),
+	"datetime": fixedType("DATETIME"),
+	"datetime2": fixedType("DATETIME"),
+	"datetimeoffset": fixedType("TIMESTAMP"),
+	"decimal": bigQueryDecimalType,
+	"float": fixedType("FLOAT64"),
+	"geography": fixedType("GEOGRAPHY"),
+	"image": fixedType("BYTES"),
+	"int": fixedType("INT64"),
+	"money": fixedType("NUMERIC(19, 4)"),
+	"nchar": fixedType("STRING"),
+	"ntext": fixedType("STRING"),
+	"numeric": bigQueryDecimalType,
+	"nvarchar": fixedType("STRING"),
+	"real": fixedType("FLOAT64"),
+	"smalldatetime": fixedType("DATETIME"),
+	"smallint": fixedType("INT64"),
+	"smallmoney": fixedType("NUMERIC(10, 4)"),
+	"text": fixedType("STRING"),
+	"time": fixedType("TIME"),
+	"timestamp": fixedType("BYTES"),
+	"tinyint": fixedType("INT64"),
+	"uniqueidentifier": fixedType("STRING"),
+	"varbinary": fixedType("BYTES"),
+	"varchar": fixedType("STRING"),
+	"xml": fixedType("STRING"),
+}
+
+// A mapping which always renders the same type.
+func fixedType(name string) TypeMapping {
+	return func(column Column) string {
+		return name
+	}
+}
+
+// A mapping which carries the declared length, or falls back to an unbounded type for MAX.
+func lengthType(name string, unbounded string) TypeMapping {
+	return func(column Column) string {
+		length := typeLength(column)
+		if length == "MAX" {
+			return unbounded
+		}
+		return fmt.Sprintf("%s(%s)", name, length)
+	}
+}
+
+// A mapping which carries the fractional second scale, capped to what the target supports.
+func scaleType(name string, maximum int) TypeMapping {
+	return func(column Column) string {
+		scale, err := strconv.Atoi(column.scale.String)
+		if err != nil {
+			return name
+		}
+		if scale > maximum {
+			scale = maximum
+		}
+		return fmt.Sprintf("%s(%d)", name, scale)
+	}
+}
+
+// A mapping which carries the precision and scale.
+func decimalType(name string) TypeMapping {
+	return func(column Column) string {
+		return fmt.Sprintf("%s(%s, %s)", name, column.precision.String, column.scale.String)
+	}
+}
+
+// A mapping which picks the single or double precision type from the float precision.
+func floatType(single string, double string) TypeMapping {
+	return func(column Column) string {
+		precision, err := strconv.Atoi(column.precision.String)
+		if err == nil && precision <= 24 {
+			return single
+		}
+		return double
+	}
+}
+
+// BigQuery NUMERIC holds 29 integer and 9 fractional digits, anything wider needs BIGNUMERIC.
+func bigQueryDecimalType(column Column) string {
+	precision, _ := strconv.Atoi(column.precision.String)
+	scale, _ := strconv.Atoi(column.scale.String)
+	if scale <= 9 && precision - scale <= 29 {
+		return fmt.Sprintf("NUMERIC(%d, %d)", precision, scale)
+	}
+	return fmt.Sprintf("BIGNUMERIC(%d, %d)", precision, scale)
+}
+
+// ANSI double quoted identifiers.
+func doubleQuote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Hive and Spark backtick quoted identifiers.
+func backtickQuote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// BigQuery backtick quoted identifiers, which escape with a backslash.
+func bigQueryQuote(name string) string {
+	name = strings.Replace(name, `\`, `\\`, -1)
+	return "`" + strings.Replace(name, "`", "\\`", -1) + "`"
+}
+
+// ANSI string literals, which only escape quotes.
+func standardLiteral(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// Snowflake string literals, which also treat backslash as an escape.
+func snowflakeLiteral(value string) string {
+	return standardLiteral(strings.Replace(value, `\`, `\\`, -1))
+}
+
+// Hive string literals, which escape with a backslash.
+func hiveLiteral(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", `\'`, -1) + "'"
+}
+
+// BigQuery double quoted string literals, which escape with a backslash.
+func bigQueryLiteral(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return `"` + strings.Replace(value, `"`, `\"`, -1) + `"`
+}
+
+// Sorted names of the registered dialects.
+func dialectNames() []string {
+	names := make([]string, 0)
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render the type of a column in the dialect, false when the source type has no mapping.
+func (dialect Dialect) columnType(column Column) (string, bool) {
+	mapping, ok := dialect.types[column.dataType.String]
+	if !ok {
+		return "", false
+	}
+	return mapping(column), true
+}
+
+// Quote each name in the dialect and join them into a column list.
+func (dialect Dialect) quoteList(names []string) string {
+	quoted := make([]string, 0)
+	for _, name := range names {
+		quoted = append(quoted, dialect.quote(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Quote the schema and name of a table separately, leaving off dbo like the results do.
+func (dialect Dialect) tableName(identity string) string {
+	table := splitIdentity(identity)
+	if table.schema == "dbo" {
+		return dialect.quote(table.name)
+	}
+	return dialect.quote(table.schema) + "." + dialect.quote(table.name)
+}
+
+// Build the definition of a table in the dialect, along with the columns which could not be mapped.
+func (dialect Dialect) definition(table Table) (TableDefinition, []Column) {
+
+	definition := TableDefinition{name: dialect.tableName(table.name)}
+	unmapped := make([]Column, 0)
+
+	for _, column := range table.columns {
+		dataType, ok := dialect.columnType(column)
+		if !ok {
+			unmapped = append(unmapped, column)
+			definition.comments = append(definition.comments, fmt.Sprintf("Column %s of type %s has no %s mapping and was left out",
+				column.name.String,
+				column.dataType.String,
+				dialect.name,
+			))
+			continue
+		}
+
+		var null string
+		if dialect.notNull && column.nullable.String == "false" {
+			null = " NOT NULL"
+		}
+
+		// Hive and BigQuery describe a column inline, the others with a statement once the table exists.
+		var comment string
+		if column.description.String != "" {
+			switch dialect.comments {
+				case "hive":
+					comment = " COMMENT " + dialect.literal(column.description.String)
+				case "bigquery":
+					comment = " OPTIONS(description=" + dialect.literal(column.description.String) + ")"
+				case "statement":
+					definition.statements = append(definition.statements, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;",
+						dialect.tableName(table.name),
+						dialect.quote(column.name.String),
+						dialect.literal(column.description.String),
+					))
+			}
+		}
+
+		definition.columns = append(definition.columns, fmt.Sprintf("%s %s%s%s",
+			dialect.quote(column.name.String),
+			dataType,
+			null,
+			comment,
+		))
+	}
+
+	if table.description != "" {
+		var statement string
+		switch dialect.comments {
+			case "hive":
+				statement = "ALTER TABLE %s SET TBLPROPERTIES ('comment' = %s);"
+			case "bigquery":
+				statement = "ALTER TABLE %s SET OPTIONS(description=%s);"
+			default:
+				statement = "COMMENT ON TABLE %s IS %s;"
+		}
+		definition.statements = append([]string{fmt.Sprintf(statement, dialect.tableName(table.name), dialect.literal(table.description))}, definition.statements...)
+	}
+
+	if dialect.primaryKey != "" && len(table.primaryKey.columns) > 0 {
+		keyColumns := make([]string, 0)
+		for _, column := range table.primaryKey.columns {
+			keyColumns = append(keyColumns, column.name)
+		}
+
+		// BigQuery keys cannot be named.
+		constraint := fmt.Sprintf(dialect.primaryKey, dialect.quoteList(keyColumns))
+		if dialect.namedKey {
+			constraint = fmt.Sprintf("CONSTRAINT %s %s", dialect.quote(table.primaryKey.name), constraint)
+		}
+		definition.constraints = append(definition.constraints, constraint)
+	}
+
+	if dialect.constraints {
+		for _, unique := range table.uniqueConstraints {
+			definition.constraints = append(definition.constraints, fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)",
+				dialect.quote(unique.name),
+				dialect.quoteList(unique.columns),
+			))
+		}
+		for _, foreignKey := range table.foreignKeys {
+			definition.constraints = append(definition.constraints, fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+				dialect.quote(foreignKey.name),
+				dialect.quoteList(foreignKey.columns),
+				dialect.tableName(foreignKey.referencedTable),
+				dialect.quoteList(foreignKey.referencedColumns),
+			))
+		}
+	}
+
+	if dialect.indexes {
+		for _, index := range table.indexes {
+			if index.primaryKey || index.uniqueConstraint {
+				continue
+			}
+			definition.statements = append(definition.statements, dialect.createIndexStatement(table.name, index))
+		}
+	}
+
+	return definition, unmapped
+}
+
+// Build a plain CREATE INDEX statement, leaving out anything specific to SQL Server.
+func (dialect Dialect) createIndexStatement(tableName string, index Index) string {
+
+	if index.indexType != "CLUSTERED" && index.indexType != "NONCLUSTERED" {
+		return fmt.Sprintf("-- Index %s of type %s is not scripted", index.name, index.indexType)
+	}
+	if index.filterDefinition != "" {
+		return fmt.Sprintf("-- Filtered index %s is not scripted", index.name)
+	}
+
+	var unique string
+	if index.unique {
+		unique = "UNIQUE "
+	}
+
+	keyColumns := make([]string, 0)
+	for _, column := range index.keyColumns {
+		if column.descending {
+			keyColumns = append(keyColumns, dialect.quote(column.name) + " DESC")
+		} else {
+			keyColumns = append(keyColumns, dialect.quote(column.name))
+		}
+	}
+
+	statement := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)",
+		unique,
+		dialect.quote(index.name),
+		dialect.tableName(tableName),
+		strings.Join(keyColumns, ", "),
+	)
+	if len(index.includedColumns) > 0 {
+		statement += fmt.Sprintf(" INCLUDE (%s)", dialect.quoteList(index.includedColumns))
+	}
+
+	return statement + ";"
+}
+
+func writeTargetDescribes(tables []Table, dialect Dialect, folder string) {
+
+	// Every column which could not be mapped, by table.
+	unmappedFile, err := os.Create(folder + string(filepath.Separator) + "unmapped.csv")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer unmappedFile.Close()
+
+	// Create the CSV writer from the file handle.
+	writer := csv.NewWriter(unmappedFile)
+
+	// Write the header row.
+	writer.Write(
+		[]string{
+			"Table Name",
+			"Column Name",
+			"Data Type",
+		},
+	)
+
+	for _, table := range tables {
+
+		definition, unmapped := dialect.definition(table)
+		for _, column := range unmapped {
+			log.Println(fmt.Sprintf("No %s mapping for %s.%s of type %s", dialect.name, table.name, column.name.String, column.dataType.String))
+			writer.Write([]string{
+				table.name,
+				column.name.String,
+				column.dataType.String,
+			})
+		}
+
+		// Create the SQL file handle.
+		outFile, err := os.Create(folder + string(filepath.Separator) + table.name + ".sql")
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		outFile.WriteString(definition.String())
+		outFile.Close()
+	}
+
+	// Flush the rows out to the file.
+	writer.Flush()
+}
\ No newline at end of file
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Typedef for a table as shown in the docs
+type docsTable struct {
+	CatalogTable
+	Columns []docsColumn
+	Search string
+}
+
+// Typedef for a column as shown in the docs
+type docsColumn struct {
+	CatalogColumn
+	Type string
+	References []docsReference
+}
+
+// Typedef for a foreign key reference, linked when the referenced table is in the catalog
+type docsReference struct {
+	Table string
+	Column string
+	Linked bool
+}
+
+// Typedef for the values handed to the docs templates
+type docsPage struct {
+	Database CatalogDatabase
+	Generated string
+	Tables []docsTable
+	Table docsTable
+}
+
+const docsStyle = `
+	body { font-family: sans-serif; margin: 2em; color: #222; }
+	table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+	th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+	th { background: #eee; }
+	td.number { text-align: right; }
+	input[type=search] { width: 100%; padding: 0.5em; margin-bottom: 1em; font-size: 1em; }
+	.key { font-weight: bold; }
+`
+
+var docsIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Database.Name}} data dictionary</title>
+<style>` + docsStyle + `</style>
+</head>
+<body>
+<h1>{{.Database.Name}} data dictionary</h1>
+<p>{{.Database.Server}}, SQL Server {{.Database.ServerVersion}}, collation {{.Database.Collation}}. Generated {{.Generated}}.</p>
+<input id="search" type="search" placeholder="Search tables and columns" autofocus>
+<table id="tables">
+<thead><tr><th>Table</th><th>Rows</th><th>Columns</th><th>Watermark</th><th>Description</th></tr></thead>
+<tbody>
+{{range .Tables}}<tr data-search="{{.Search}}"><td><a href="tables/{{.Name}}.html">{{.Name}}</a></td><td class="number">{{.RowCount}}</td><td class="number">{{len .Columns}}</td><td>{{if .Type2}}Type 2{{else}}{{.Watermark}}{{end}}</td><td>{{.Description}}</td></tr>
+{{end}}</tbody>
+</table>
+<script>
+document.getElementById("search").addEventListener("input", function () {
+	var term = this.value.toLowerCase();
+	var rows = document.querySelectorAll("#tables tbody tr");
+	for (var i = 0; i < rows.length; i++) {
+		rows[i].style.display = rows[i].getAttribute("data-search").indexOf(term) === -1 ? "none" : "";
+	}
+});
+</script>
+</body>
+</html>
+`))
+
+var docsTableTemplate = template.Must(template.New("table").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Table.Name}}</title>
+<style>` + docsStyle + `</style>
+</head>
+<body>
+<p><a href="../index.html">{{.Database.Name}}</a></p>
+<h1>{{.Table.Name}}</h1>
+{{if .Table.Description}}<p>{{.Table.Description}}</p>
+{{end}}<p>{{.Table.RowCount}} rows.{{if .Table.Type2}} Loaded in full as a Type 2 table.{{else if .Table.Watermark}} Deltas on {{.Table.Watermark}}.{{end}}</p>
+<h2>Columns</h2>
+<table>
+<thead><tr><th>#</th><th>Column</th><th>Type</th><th>Nullable</th><th>Key</th><th>Default</th><th>Description</th></tr></thead>
+<tbody>
+{{range .Table.Columns}}<tr id="{{.Name}}"><td class="number">{{.Ordinal}}</td><td>{{.Name}}{{if .LogicalName}}<br>{{if .Structure}}{{.Structure}}.{{end}}{{.LogicalName}}{{end}}</td><td>{{.Type}}</td><td>{{if .Nullable}}Yes{{else}}No{{end}}</td><td>{{if .PrimaryKey}}<span class="key">PK</span> {{end}}{{range .References}}FK <a{{if .Linked}} href="{{.Table}}.html#{{.Column}}"{{end}}>{{.Table}}.{{.Column}}</a> {{end}}</td><td>{{.Default}}</td><td>{{.Description}}</td></tr>
+{{end}}</tbody>
+</table>
+{{if .Table.Indexes}}<h2>Indexes</h2>
+<table>
+<thead><tr><th>Index</th><th>Type</th><th>Unique</th><th>Key Columns</th><th>Included Columns</th><th>Filter</th></tr></thead>
+<tbody>
+{{range .Table.Indexes}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{if .Unique}}Yes{{else}}No{{end}}</td><td>{{range $index, $column := .KeyColumns}}{{if $index}}, {{end}}{{$column}}{{end}}</td><td>{{range $index, $column := .IncludedColumns}}{{if $index}}, {{end}}{{$column}}{{end}}</td><td>{{.Filter}}</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}</body>
+</html>
+`))
+
+// Write the HTML site and Markdown dictionary for a run.
+func writeDocs(run string, out string) error {
+	sep := string(filepath.Separator)
+
+	if run == "" {
+		latest := findRun("results", "", "catalog.json")
+		if latest == "" {
+			return errors.New("No run with a catalog.json was found in results")
+		}
+		run = "results" + sep + latest
+	}
+	if out == "" {
+		out = run + sep + "docs"
+	}
+
+	catalog, err := loadCatalog(run + sep + "catalog.json")
+	if err != nil {
+		return err
+	}
+
+	log.Println(fmt.Sprintf("Writing the docs for %s to %s", run, out))
+
+	err = os.MkdirAll(out + sep + "tables", 0777)
+	if err != nil {
+		return err
+	}
+
+	page := docsPage{
+		Database: catalog.Database,
+		Generated: catalog.Generated.Format("2006-01-02 15:04"),
+		Tables: docsTables(catalog),
+	}
+
+	err = writeTemplate(out + sep + "index.html", docsIndexTemplate, page)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range page.Tables {
+		page.Table = table
+		err = writeTemplate(out + sep + "tables" + sep + table.Name + ".html", docsTableTemplate, page)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(out + sep + "data_dictionary.md", []byte(docsMarkdown(page)), 0666)
+}
+
+// Build the docs view of every catalog table.
+func docsTables(catalog *Catalog) []docsTable {
+
+	known := make(map[string]bool)
+	for _, table := range catalog.Tables {
+		known[table.Name] = true
+	}
+
+	tables := make([]docsTable, 0)
+	for _, table := range catalog.Tables {
+
+		// Foreign key references by column.
+		references := make(map[string][]docsReference)
+		for _, foreignKey := range table.ForeignKeys {
+			for index, column := range foreignKey.Columns {
+				references[column] = append(references[column], docsReference{
+					Table: foreignKey.ReferencedTable,
+					Column: foreignKey.ReferencedColumns[index],
+					Linked: known[foreignKey.ReferencedTable],
+				})
+			}
+		}
+
+		result := docsTable{CatalogTable: table}
+		search := []string{table.Name}
+		if table.Description != "" {
+			search = append(search, table.Description)
+		}
+
+		for _, column := range table.Columns {
+			result.Columns = append(result.Columns, docsColumn{
+				CatalogColumn: column,
+				Type: displayType(column),
+				References: references[column.Name],
+			})
+			search = append(search, column.Name)
+			if column.LogicalName != "" {
+				search = append(search, column.LogicalName)
+			}
+			if column.Description != "" {
+				search = append(search, column.Description)
+			}
+		}
+
+		result.Search = strings.ToLower(strings.Join(search, " "))
+		tables = append(tables, result)
+	}
+
+	return tables
+}
+
+// The SQL Server type of a catalog column without the brackets, such as nvarchar(50).
+func displayType(column CatalogColumn) string {
+	return strings.Replace(strings.Replace(sqlServerType(column.column()), "[", "", 1), "]", "", 1)
+}
+
+func writeTemplate(path string, page *template.Template, data interface{}) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	return page.Execute(outFile, data)
+}
+
+// Escape the characters which would break a Markdown table cell.
+func markdownCell(value string) string {
+	value = strings.Replace(value, "|", "\\|", -1)
+	return strings.Replace(value, "\n", " ", -1)
+}
+
+// The anchor Markdown renderers give a heading, lower case with spaces as hyphens and other punctuation dropped.
+func markdownAnchor(heading string) string {
+	var anchor strings.Builder
+	for _, character := range strings.ToLower(heading) {
+		switch {
+			case unicode.IsLetter(character) || unicode.IsDigit(character) || character == '-' || character == '_':
+				anchor.WriteRune(character)
+			case character == ' ':
+				anchor.WriteRune('-')
+		}
+	}
+	return anchor.String()
+}
+
+// Render the Markdown data dictionary.
+func docsMarkdown(page docsPage) string {
+
+	var markdown strings.Builder
+	markdown.WriteString(fmt.Sprintf("# %s data dictionary\n\n", page.Database.Name))
+	markdown.WriteString(fmt.Sprintf("%s, SQL Server %s, collation %s. Generated %s.\n\n",
+		page.Database.Server,
+		page.Database.ServerVersion,
+		page.Database.Collation,
+		page.Generated,
+	))
+
+	markdown.WriteString("| Table | Rows | Columns | Description |\n|---|---:|---:|---|\n")
+	for _, table := range page.Tables {
+		markdown.WriteString(fmt.Sprintf("| [%s](#%s) | %d | %d | %s |\n",
+			markdownCell(table.Name),
+			markdownAnchor(table.Name),
+			table.RowCount,
+			len(table.Columns),
+			markdownCell(table.Description),
+		))
+	}
+
+	for _, table := range page.Tables {
+		markdown.WriteString(fmt.Sprintf("\n## %s\n\n", table.Name))
+		if table.Description != "" {
+			markdown.WriteString(table.Description + "\n\n")
+		}
+		markdown.WriteString("| # | Column | Type | Nullable | Key | Default | Description |\n|---:|---|---|---|---|---|---|\n")
+
+		for _, column := range table.Columns {
+			keys := make([]string, 0)
+			if column.PrimaryKey {
+				keys = append(keys, "PK")
+			}
+			for _, reference := range column.References {
+				if reference.Linked {
+					keys = append(keys, fmt.Sprintf("FK [%s.%s](#%s)", reference.Table, reference.Column, markdownAnchor(reference.Table)))
+				} else {
+					keys = append(keys, fmt.Sprintf("FK %s.%s", reference.Table, reference.Column))
+				}
+			}
+
+			nullable := "No"
+			if column.Nullable {
+				nullable = "Yes"
+			}
+
+			markdown.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |\n",
+				strconv.Itoa(column.Ordinal),
+				markdownCell(column.Name),
+				markdownCell(column.Type),
+				nullable,
+				markdownCell(strings.Join(keys, ", ")),
+				markdownCell(column.Default),
+				markdownCell(column.Description),
+			))
+		}
+	}
+
+	return markdown.String()
+}
\ No newline at end of file
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDocs(t *testing.T) {
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	catalog := Catalog{
+		Version: catalogVersion,
+		Database: CatalogDatabase{Name: "P_ServiceManagerSS"},
+		Tables: []CatalogTable{
+			{Name: "INCIDENTSM1", RowCount: 10, Columns: []CatalogColumn{
+				{Name: "INCIDENT_ID", DataType: "varchar", MaxLength: 60, PrimaryKey: true, Ordinal: 1},
+				{Name: "LOCATION", DataType: "nvarchar", MaxLength: 120, Nullable: true, Ordinal: 2},
+			}, ForeignKeys: []CatalogForeignKey{
+				{Name: "FK_LOCATION", Columns: []string{"LOCATION"}, ReferencedTable: "LOCM1", ReferencedColumns: []string{"LOCATION"}},
+			}},
+			{Name: "LOCM1", RowCount: 5, Columns: []CatalogColumn{
+				{Name: "LOCATION", DataType: "nvarchar", MaxLength: 120, PrimaryKey: true, Ordinal: 1},
+			}},
+		},
+	}
+	writeJSON(filepath.Join(directory, "catalog.json"), catalog)
+
+	err = writeDocs(directory, "")
+	if err != nil {
+		t.Fatal("Could not write the docs", err)
+	}
+
+	index, _ := ioutil.ReadFile(filepath.Join(directory, "docs", "index.html"))
+	if !strings.Contains(string(index), `<a href="tables/LOCM1.html">LOCM1</a>`) || !strings.Contains(string(index), `data-search="incidentsm1 incident_id location"`) {
+		t.Fatal("Index page is missing the table list or search text", string(index))
+	}
+
+	page, _ := ioutil.ReadFile(filepath.Join(directory, "docs", "tables", "INCIDENTSM1.html"))
+	if !strings.Contains(string(page), `FK <a href="LOCM1.html#LOCATION">LOCM1.LOCATION</a>`) || !strings.Contains(string(page), "nvarchar(60)") {
+		t.Fatal("Table page is missing the foreign key link or column type", string(page))
+	}
+
+	markdown, _ := ioutil.ReadFile(filepath.Join(directory, "docs", "data_dictionary.md"))
+	if !strings.Contains(string(markdown), "| 2 | LOCATION | nvarchar(60) | Yes | FK [LOCM1.LOCATION](#locm1) |  |") {
+		t.Fatal("Markdown dictionary is missing the column row", string(markdown))
+	}
+}
+
+func TestMarkdownAnchor(t *testing.T) {
+	for heading, anchor := range map[string]string{
+		"INCIDENTSM1": "incidentsm1",
+		"sales.Orders": "salesorders",
+		"Order Lines": "order-lines",
+		"open_by_group": "open_by_group",
+	} {
+		if markdownAnchor(heading) != anchor {
+			t.Error("Unexpected anchor for", heading, markdownAnchor(heading))
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Typedef for the schema changes between two runs, written out as schema_changes.json
+type SchemaChanges struct {
+	Previous string `json:"previous"`
+	AddedTables []string `json:"addedTables"`
+	RemovedTables []string `json:"removedTables"`
+	Tables []TableChanges `json:"tables"`
+}
+
+// Typedef for the column changes of a single table
+type TableChanges struct {
+	Name string `json:"name"`
+	AddedColumns []CatalogColumn `json:"addedColumns"`
+	RemovedColumns []CatalogColumn `json:"removedColumns"`
+	ChangedColumns []ColumnChange `json:"changedColumns"`
+}
+
+// Compare the catalog with the previous run which extracted. Tables stopped for drift are kept in the state with the
+// run they last matched, and are compared with that run until the drift is accepted, so a later catalog which already
+// has the new schema does not hide it.
+func trackDrift(results string, previousRun string, current Catalog, state *State, stop bool) (SchemaChanges, error) {
+	sep := string(filepath.Separator)
+
+	catalogs := make(map[string]*Catalog)
+	load := func(run string) (*Catalog, error) {
+		if catalog, ok := catalogs[run]; ok {
+			return catalog, nil
+		}
+		catalog, err := loadCatalog(results + sep + run + sep + "catalog.json")
+		catalogs[run] = catalog
+		return catalog, err
+	}
+
+	previous, err := load(previousRun)
+	if err != nil {
+		return SchemaChanges{}, err
+	}
+	changes := compareCatalogs(previousRun, previous, current)
+
+	currentTables := make(map[string]CatalogTable)
+	for _, table := range current.Tables {
+		currentTables[table.Name] = table
+	}
+
+	for name, run := range state.Drift {
+		if run == previousRun {
+			continue
+		}
+		baseline, err := load(run)
+		if err != nil {
+			return SchemaChanges{}, err
+		}
+
+		// A table which is gone, or is back to the schema it last matched, has nothing left to stop.
+		var baselineTable CatalogTable
+		found := false
+		for _, table := range baseline.Tables {
+			if table.Name == name {
+				baselineTable, found = table, true
+			}
+		}
+		table, ok := currentTables[name]
+		tableChanges := compareColumns(baselineTable, table)
+		if !found || !ok || len(tableChanges.AddedColumns) + len(tableChanges.RemovedColumns) + len(tableChanges.ChangedColumns) == 0 {
+			delete(state.Drift, name)
+			continue
+		}
+
+		replaced := false
+		for index := range changes.Tables {
+			if changes.Tables[index].Name == name {
+				changes.Tables[index] = tableChanges
+				replaced = true
+			}
+		}
+		if !replaced {
+			changes.Tables = append(changes.Tables, tableChanges)
+		}
+	}
+
+	// Only the tables which still drifted are stopped again, extracting a table accepts its new schema.
+	drift := make(map[string]string)
+	if stop {
+		for _, table := range changes.Tables {
+			drift[table.Name] = previousRun
+			if run, ok := state.Drift[table.Name]; ok {
+				drift[table.Name] = run
+			}
+		}
+	}
+	state.Drift = drift
+	return changes, nil
+}
+
+// Typedef for a column whose definition changed, listing the catalog fields which differ
+type ColumnChange struct {
+	Name string `json:"name"`
+	Fields []string `json:"fields"`
+	Previous CatalogColumn `json:"previous"`
+	Current CatalogColumn `json:"current"`
+}
+
+// Compare the tables of two catalogs.
+func compareCatalogs(previousRun string, previous *Catalog, current Catalog) SchemaChanges {
+
+	changes := SchemaChanges{
+		Previous: previousRun,
+		AddedTables: make([]string, 0),
+		RemovedTables: make([]string, 0),
+		Tables: make([]TableChanges, 0),
+	}
+
+	previousTables := make(map[string]CatalogTable)
+	for _, table := range previous.Tables {
+		previousTables[table.Name] = table
+	}
+
+	currentTables := make(map[string]bool)
+	for _, table := range current.Tables {
+		currentTables[table.Name] = true
+
+		previousTable, ok := previousTables[table.Name]
+		if !ok {
+			changes.AddedTables = append(changes.AddedTables, table.Name)
+			continue
+		}
+
+		tableChanges := compareColumns(previousTable, table)
+		if len(tableChanges.AddedColumns) > 0 || len(tableChanges.RemovedColumns) > 0 || len(tableChanges.ChangedColumns) > 0 {
+			changes.Tables = append(changes.Tables, tableChanges)
+		}
+	}
+
+	for _, table := range previous.Tables {
+		if !currentTables[table.Name] {
+			changes.RemovedTables = append(changes.RemovedTables, table.Name)
+		}
+	}
+
+	return changes
+}
+
+// Compare the columns of the same table from two catalogs.
+func compareColumns(previous CatalogTable, current CatalogTable) TableChanges {
+
+	changes := TableChanges{
+		Name: current.Name,
+		AddedColumns: make([]CatalogColumn, 0),
+		RemovedColumns: make([]CatalogColumn, 0),
+		ChangedColumns: make([]ColumnChange, 0),
+	}
+
+	previousColumns := make(map[string]CatalogColumn)
+	for _, column := range previous.Columns {
+		previousColumns[column.Name] = column
+	}
+
+	currentColumns := make(map[string]bool)
+	for _, column := range current.Columns {
+		currentColumns[column.Name] = true
+
+		previousColumn, ok := previousColumns[column.Name]
+		if !ok {
+			changes.AddedColumns = append(changes.AddedColumns, column)
+			continue
+		}
+
+		fields := make([]string, 0)
+		if previousColumn.DataType != column.DataType {
+			fields = append(fields, "dataType")
+		}
+		if previousColumn.MaxLength != column.MaxLength {
+			fields = append(fields, "maxLength")
+		}
+		if previousColumn.Precision != column.Precision {
+			fields = append(fields, "precision")
+		}
+		if previousColumn.Scale != column.Scale {
+			fields = append(fields, "scale")
+		}
+		if previousColumn.Nullable != column.Nullable {
+			fields = append(fields, "nullable")
+		}
+		if previousColumn.PrimaryKey != column.PrimaryKey {
+			fields = append(fields, "primaryKey")
+		}
+		if previousColumn.Computed != column.Computed || previousColumn.Persisted != column.Persisted {
+			fields = append(fields, "computed")
+		}
+
+		if len(fields) > 0 {
+			changes.ChangedColumns = append(changes.ChangedColumns, ColumnChange{
+				Name: column.Name,
+				Fields: fields,
+				Previous: previousColumn,
+				Current: column,
+			})
+		}
+	}
+
+	for _, column := range previous.Columns {
+		if !currentColumns[column.Name] {
+			changes.RemovedColumns = append(changes.RemovedColumns, column)
+		}
+	}
+
+	return changes
+}
+
+// Whether any table was added, removed or changed.
+func (changes SchemaChanges) any() bool {
+	return len(changes.AddedTables) > 0 || len(changes.RemovedTables) > 0 || len(changes.Tables) > 0
+}
+
+// Names of the tables whose columns changed.
+func (changes SchemaChanges) affected() map[string]bool {
+	affected := make(map[string]bool)
+	for _, table := range changes.Tables {
+		affected[table.Name] = true
+	}
+	return affected
+}
+
+// Render a column for the report, such as [nvarchar](50) NOT NULL.
+func describeCatalogColumn(column CatalogColumn) string {
+	description := sqlServerType(column.column())
+	if column.Nullable {
+		description += " NULL"
+	} else {
+		description += " NOT NULL"
+	}
+	if column.PrimaryKey {
+		description += " PRIMARY KEY"
+	}
+	if column.Computed != "" {
+		description += " AS " + column.Computed
+	}
+	if column.Persisted {
+		description += " PERSISTED"
+	}
+	return description
+}
+
+// Render the changes as a readable report.
+func (changes SchemaChanges) report() string {
+
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("Schema changes since %s\n", changes.Previous))
+
+	if !changes.any() {
+		report.WriteString("\nNo changes\n")
+		return report.String()
+	}
+
+	if len(changes.AddedTables) > 0 {
+		report.WriteString("\nAdded tables\n")
+		for _, name := range changes.AddedTables {
+			report.WriteString("\t" + name + "\n")
+		}
+	}
+
+	if len(changes.RemovedTables) > 0 {
+		report.WriteString("\nRemoved tables\n")
+		for _, name := range changes.RemovedTables {
+			report.WriteString("\t" + name + "\n")
+		}
+	}
+
+	for _, table := range changes.Tables {
+		report.WriteString("\n" + table.Name + "\n")
+		for _, column := range table.AddedColumns {
+			report.WriteString(fmt.Sprintf("\t+ %s %s\n", column.Name, describeCatalogColumn(column)))
+		}
+		for _, column := range table.RemovedColumns {
+			report.WriteString(fmt.Sprintf("\t- %s %s\n", column.Name, describeCatalogColumn(column)))
+		}
+		for _, column := range table.ChangedColumns {
+			report.WriteString(fmt.Sprintf("\t~ %s %s -> %s\n",
+				column.Name,
+				describeCatalogColumn(column.Previous),
+				describeCatalogColumn(column.Current),
+			))
+		}
+	}
+
+	return report.String()
+}
+
+func writeSchemaChanges(changes SchemaChanges, folder string) {
+	writeJSON(folder + "schema_changes.json", changes)
+
+	err := ioutil.WriteFile(folder + "schema_changes.txt", []byte(changes.report()), 0666)
+	if err != nil {
+		log.Println(err)
+	}
+}
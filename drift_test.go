@@ -0,0 +1,235 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareCatalogs(t *testing.T) {
+	previous := &Catalog{
+		Tables: []CatalogTable{
+			{Name: "INCIDENTSM1", Columns: []CatalogColumn{
+				{Name: "INCIDENT_ID", DataType: "varchar", MaxLength: 60, PrimaryKey: true},
+				{Name: "TITLE", DataType: "varchar", MaxLength: 100, Nullable: true},
+				{Name: "FOLDER", DataType: "varchar", MaxLength: 60, Nullable: true},
+			}},
+			{Name: "OLDM1"},
+			{Name: "LOCM1", Columns: []CatalogColumn{{Name: "LOCATION", DataType: "varchar", MaxLength: 60}}},
+		},
+	}
+	current := Catalog{
+		Tables: []CatalogTable{
+			{Name: "INCIDENTSM1", Columns: []CatalogColumn{
+				{Name: "INCIDENT_ID", DataType: "varchar", MaxLength: 60, PrimaryKey: true},
+				{Name: "TITLE", DataType: "nvarchar", MaxLength: 400, Nullable: false},
+				{Name: "SYSMODTIME", DataType: "datetime", Nullable: true},
+			}},
+			{Name: "LOCM1", Columns: []CatalogColumn{{Name: "LOCATION", DataType: "varchar", MaxLength: 60}}},
+			{Name: "NEWM1"},
+		},
+	}
+
+	changes := compareCatalogs("2018_07_01", previous, current)
+
+	if len(changes.AddedTables) != 1 || changes.AddedTables[0] != "NEWM1" {
+		t.Fatal("Added table was not found", changes.AddedTables)
+	}
+	if len(changes.RemovedTables) != 1 || changes.RemovedTables[0] != "OLDM1" {
+		t.Fatal("Removed table was not found", changes.RemovedTables)
+	}
+	if len(changes.Tables) != 1 || changes.Tables[0].Name != "INCIDENTSM1" {
+		t.Fatal("Only INCIDENTSM1 should have column changes", changes.Tables)
+	}
+
+	table := changes.Tables[0]
+	if len(table.AddedColumns) != 1 || table.AddedColumns[0].Name != "SYSMODTIME" {
+		t.Fatal("Added column was not found", table.AddedColumns)
+	}
+	if len(table.RemovedColumns) != 1 || table.RemovedColumns[0].Name != "FOLDER" {
+		t.Fatal("Removed column was not found", table.RemovedColumns)
+	}
+	if len(table.ChangedColumns) != 1 || strings.Join(table.ChangedColumns[0].Fields, ",") != "dataType,maxLength,nullable" {
+		t.Fatal("Changed column fields are wrong", table.ChangedColumns)
+	}
+
+	if !changes.affected()["INCIDENTSM1"] || changes.affected()["LOCM1"] {
+		t.Fatal("Affected tables are wrong", changes.affected())
+	}
+	if !strings.Contains(changes.report(), "~ TITLE [varchar](100) NULL -> [nvarchar](200) NOT NULL") {
+		t.Fatal("Report does not describe the type change", changes.report())
+	}
+}
+
+func TestMigrationStatements(t *testing.T) {
+	changes := TableChanges{
+		Name: "INCIDENTSM1",
+		AddedColumns: []CatalogColumn{
+			{Name: "SYSMODTIME", DataType: "datetime", Nullable: true},
+			{Name: "OPEN_YEAR", DataType: "int", Nullable: true, Computed: "(datepart(year,[OPEN_TIME]))", Persisted: true},
+			{Name: "ACTIVE", DataType: "bit", Default: "((1))", DefaultName: "DF_ACTIVE"},
+			{Name: "STATUS", DataType: "varchar", MaxLength: 20, Default: "(N'open')", DefaultName: "DF_STATUS"},
+			{Name: "BUCKET", DataType: "int", Default: "(abs(checksum(newid()))%(10))", DefaultName: "DF_BUCKET"},
+			{Name: "REGION", DataType: "varchar", MaxLength: 20},
+		},
+		RemovedColumns: []CatalogColumn{
+			{Name: "FOLDER", DataType: "varchar", MaxLength: 60, Nullable: true},
+		},
+		ChangedColumns: []ColumnChange{{
+			Name: "TITLE",
+			Fields: []string{"dataType", "maxLength", "nullable"},
+			Previous: CatalogColumn{Name: "TITLE", DataType: "varchar", MaxLength: 100, Nullable: true},
+			Current: CatalogColumn{Name: "TITLE", DataType: "nvarchar", MaxLength: 400, Nullable: false},
+		}, {
+			Name: "CLOSE_YEAR",
+			Fields: []string{"computed"},
+			Previous: CatalogColumn{Name: "CLOSE_YEAR", DataType: "int", Nullable: true, Computed: "(datepart(year,[CLOSE_TIME]))"},
+			Current: CatalogColumn{Name: "CLOSE_YEAR", DataType: "int", Nullable: true, Computed: "(datepart(year,[CLOSE_TIME]))", Persisted: true},
+		}, {
+			Name: "OPEN_WEEK",
+			Fields: []string{"computed"},
+			Previous: CatalogColumn{Name: "OPEN_WEEK", DataType: "int", Nullable: true, Computed: "(datepart(week,[OPEN_TIME]))"},
+			Current: CatalogColumn{Name: "OPEN_WEEK", DataType: "int", Nullable: true},
+		}},
+	}
+
+	sqlServer := strings.Join(sqlServerMigration(changes), "\n")
+	expected := []string{
+		"ALTER TABLE [INCIDENTSM1] ADD [SYSMODTIME] [datetime] NULL;",
+		"ALTER TABLE [INCIDENTSM1] ADD [OPEN_YEAR] AS (datepart(year,[OPEN_TIME])) PERSISTED;",
+		"ALTER TABLE [INCIDENTSM1] ALTER COLUMN [TITLE] [nvarchar](200) NOT NULL;",
+		"-- WARNING: OPEN_WEEK is added again empty, reload it from the source\nALTER TABLE [INCIDENTSM1] DROP COLUMN [OPEN_WEEK];\nALTER TABLE [INCIDENTSM1] ADD [OPEN_WEEK] [int] NULL;",
+		"ALTER TABLE [INCIDENTSM1] DROP COLUMN [CLOSE_YEAR];\nALTER TABLE [INCIDENTSM1] ADD [CLOSE_YEAR] AS (datepart(year,[CLOSE_TIME])) PERSISTED;",
+		"-- ALTER TABLE [INCIDENTSM1] DROP COLUMN [FOLDER];",
+	}
+	for _, statement := range expected {
+		if !strings.Contains(sqlServer, statement) {
+			t.Error("SQL Server migration is missing", statement, "in", sqlServer)
+		}
+	}
+
+	if strings.Contains(sqlServer, "ALTER COLUMN [CLOSE_YEAR]") || strings.Contains(sqlServer, "-- WARNING: STATUS is NOT NULL") {
+		t.Error("Computed columns cannot be altered", sqlServer)
+	}
+
+	assertNoAddAfterCommentedDrop(t, sqlServerMigration(changes))
+
+	postgres := strings.Join(postgresMigration(changes), "\n")
+	expected = []string{
+		`ALTER TABLE "INCIDENTSM1" ADD COLUMN "SYSMODTIME" timestamp(3);`,
+		`ALTER TABLE "INCIDENTSM1" ADD COLUMN "ACTIVE" boolean NOT NULL DEFAULT true;`,
+		`ALTER TABLE "INCIDENTSM1" ADD COLUMN "STATUS" varchar(20) NOT NULL DEFAULT 'open';`,
+		`-- WARNING: BUCKET default not translated`,
+		`ALTER TABLE "INCIDENTSM1" ADD COLUMN "BUCKET" integer NOT NULL;`,
+		`-- WARNING: REGION is NOT NULL without a default`,
+		`ALTER TABLE "INCIDENTSM1" ALTER COLUMN "TITLE" TYPE varchar(200);`,
+		`ALTER TABLE "INCIDENTSM1" ALTER COLUMN "TITLE" SET NOT NULL;`,
+		`-- ALTER TABLE "INCIDENTSM1" DROP COLUMN "FOLDER";`,
+	}
+	for _, statement := range expected {
+		if !strings.Contains(postgres, statement) {
+			t.Error("Postgres migration is missing", statement, "in", postgres)
+		}
+	}
+	if strings.Contains(postgres, "-- WARNING: STATUS is NOT NULL") || strings.Contains(postgres, "-- WARNING: ACTIVE is NOT NULL") {
+		t.Error("Columns with a default should not be warned about", postgres)
+	}
+
+	// Tables outside dbo quote their schema and name separately.
+	changes.Name = "sales.Orders"
+	if sqlServer = strings.Join(sqlServerMigration(changes), "\n"); !strings.Contains(sqlServer, "ALTER TABLE [sales].[Orders] ADD [SYSMODTIME] [datetime] NULL;") {
+		t.Error("SQL Server migration should qualify the table", sqlServer)
+	}
+	if postgres = strings.Join(postgresMigration(changes), "\n"); !strings.Contains(postgres, `ALTER TABLE "sales"."Orders" ADD COLUMN "SYSMODTIME" timestamp(3);`) {
+		t.Error("Postgres migration should quote the schema separately", postgres)
+	}
+}
+
+func TestCatalogColumnRoundTrip(t *testing.T) {
+	column := CatalogColumn{Name: "ASSIGNEE", DataType: "nvarchar", MaxLength: 120, Nullable: true, LogicalName: "assignee.name"}
+
+	converted := column.column()
+	if converted.structure.Valid || converted.array.Valid {
+		t.Fatal("A field outside a structure or array should leave them null", converted.structure, converted.array)
+	}
+	column.Structure = "header"
+	column.Array = true
+	if converted = column.column(); converted.structure.String != "header" || converted.array.String != "true" {
+		t.Fatal("The structure and array should be kept", converted.structure, converted.array)
+	}
+	if back := catalogColumn(converted); back.Structure != "header" || !back.Array || back.LogicalName != "assignee.name" {
+		t.Fatal("The column should convert back unchanged", back)
+	}
+}
+
+// A column dropped in a commented out statement still exists, so adding it again in a live statement would fail.
+func assertNoAddAfterCommentedDrop(t *testing.T, statements []string) {
+	dropped := make(map[string]bool)
+	for _, statement := range statements {
+		if strings.HasPrefix(statement, "-- ALTER TABLE ") && strings.Contains(statement, " DROP COLUMN ") {
+			parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(statement, "-- ALTER TABLE "), ";"), " DROP COLUMN ", 2)
+			dropped[parts[0] + " ADD " + parts[1] + " "] = true
+			continue
+		}
+		for add := range dropped {
+			if strings.HasPrefix(statement, "ALTER TABLE " + add) {
+				t.Error("Live ADD after a commented out DROP of the same column", statement)
+			}
+		}
+	}
+}
+
+func TestDriftAcrossRuns(t *testing.T) {
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	old := Catalog{Tables: []CatalogTable{{Name: "INCIDENTSM1", Columns: []CatalogColumn{{Name: "TITLE", DataType: "varchar", MaxLength: 100}}}}}
+	changed := Catalog{Tables: []CatalogTable{{Name: "INCIDENTSM1", Columns: []CatalogColumn{{Name: "TITLE", DataType: "nvarchar", MaxLength: 400}}}}}
+
+	// The first run extracted with the old schema, the second was stopped by the drift and the third only described.
+	first := time.Now().AddDate(0, 0, -3).Format("2006_01_02")
+	second := time.Now().AddDate(0, 0, -2).Format("2006_01_02")
+	third := time.Now().AddDate(0, 0, -1).Format("2006_01_02")
+	for run, catalog := range map[string]Catalog{first: old, second: changed, third: changed} {
+		os.Mkdir(filepath.Join(directory, run), 0777)
+		writeJSON(filepath.Join(directory, run, "catalog.json"), catalog)
+		if run != third {
+			writeJSON(filepath.Join(directory, run, "manifest.json"), Manifest{})
+		}
+	}
+
+	state := &State{Drift: make(map[string]string)}
+	changes, err := trackDrift(directory, first, changed, state, true)
+	if err != nil || !changes.affected()["INCIDENTSM1"] || state.Drift["INCIDENTSM1"] != first {
+		t.Fatal("The drift should be found and kept", changes, state.Drift, err)
+	}
+
+	// The next run compares with the stopped run, whose catalog already has the new schema.
+	previous := findPreviousRun(directory, "catalog.json", "manifest.json")
+	if previous != second {
+		t.Fatal("Describe only runs should not be compared with", previous)
+	}
+	changes, err = trackDrift(directory, previous, changed, state, true)
+	if err != nil || !changes.affected()["INCIDENTSM1"] || state.Drift["INCIDENTSM1"] != first {
+		t.Fatal("The drift should still stop the table on the next run", changes, state.Drift, err)
+	}
+
+	// Accepting the drift extracts the table again.
+	state.Drift = make(map[string]string)
+	changes, err = trackDrift(directory, previous, changed, state, true)
+	if err != nil || changes.any() || len(state.Drift) != 0 {
+		t.Fatal("Accepted drift should not stop the table", changes, state.Drift, err)
+	}
+
+	// Warnings extract the table, which accepts the drift.
+	state.Drift = map[string]string{"INCIDENTSM1": first}
+	if _, err = trackDrift(directory, previous, changed, state, false); err != nil || len(state.Drift) != 0 {
+		t.Fatal("Extracting should accept the drift", state.Drift, err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// HPSM array tables, such as ASSIGNMENTA1, hold one row per array element.
+var arrayTable = regexp.MustCompile(`(?i)A[0-9]+$`)
+
+// Build the table which exports a logical entity, joining its tables to the first one on the shared key.
+func buildEntity(entity EntityConfig, tables []Table) (Table, error) {
+
+	if entity.Name == "" || len(entity.Tables) == 0 || len(entity.Key) == 0 {
+		return Table{}, errors.New("An entity needs a name, tables and a key")
+	}
+
+	known := make(map[string]Table)
+	for _, table := range tables {
+		known[nameKey(table.name)] = table
+	}
+
+	members := make([]Table, 0)
+	for _, name := range entity.Tables {
+		table, ok := known[nameKey(name)]
+		if !ok {
+			return Table{}, fmt.Errorf("Entity %s uses %s, which is not in the table list", entity.Name, name)
+		}
+		for _, key := range entity.Key {
+			if !hasColumn(table, key) {
+				return Table{}, fmt.Errorf("Entity %s joins on %s, which is not a column of %s", entity.Name, key, table.name)
+			}
+		}
+		members = append(members, table)
+	}
+
+	base := members[0]
+	if arrayTable.MatchString(base.name) {
+		return Table{}, fmt.Errorf("Entity %s starts with the array table %s, the first table must hold one row per key", entity.Name, base.name)
+	}
+
+	result := Table{
+		name: entity.Name,
+		kind: "entity",
+		rowCount: base.rowCount,
+		type2: base.type2,
+		drifted: base.drifted,
+		columns: append(make([]Column, 0), base.columns...),
+	}
+
+	// The watermark is qualified, the key and timestamp names can repeat across the joined tables.
+	if base.timestamp != "" {
+		result.timestamp = "t0." + quoteName(base.timestamp)
+	}
+
+	selects := []string{selectList(base.columns, "t0")}
+	joins := make([]string, 0)
+
+	for index, table := range members[1:] {
+		alias := fmt.Sprintf("t%d", index + 1)
+
+		// The key columns are already selected from the first table.
+		columns := make([]Column, 0)
+		for _, column := range table.columns {
+			if !isKey(entity, column.name.String) {
+				columns = append(columns, column)
+			}
+		}
+
+		if table.drifted {
+			result.drifted = true
+		}
+
+		// Array tables become a single JSON array column, ordered by the element number.
+		if entity.Aggregate && arrayTable.MatchString(table.name) {
+			order := ""
+			if number, ok := columnName(table, "RECORD_NUMBER"); ok {
+				order = fmt.Sprintf(" ORDER BY %s.%s", alias, quoteName(number))
+			}
+			selects = append(selects, fmt.Sprintf("(SELECT %s FROM %s %s WHERE %s%s FOR JSON PATH) AS %s",
+				selectList(columns, alias),
+				table.sqlName(),
+				alias,
+				keyCondition(entity, table, base, alias),
+				order,
+				quoteName(table.name),
+			))
+			result.columns = append(result.columns, Column{
+				name: sql.NullString{String: table.name, Valid: true},
+				dataType: sql.NullString{String: "nvarchar", Valid: true},
+				maxLength: sql.NullString{String: "-1", Valid: true},
+				nullable: sql.NullString{String: "true", Valid: true},
+			})
+			continue
+		}
+
+		selects = append(selects, selectList(columns, alias))
+		joins = append(joins, fmt.Sprintf("LEFT JOIN %s %s ON %s", table.sqlName(), alias, keyCondition(entity, table, base, alias)))
+		result.columns = append(result.columns, columns...)
+	}
+
+	result.query = fmt.Sprintf("SELECT %s FROM %s t0", strings.Join(selects, ","), base.sqlName())
+	if len(joins) > 0 {
+		result.query += " " + strings.Join(joins, " ")
+	}
+
+	return result, nil
+}
+
+// The condition joining a table alias to the first table of an entity, with the keys spelt as each table has them.
+func keyCondition(entity EntityConfig, table Table, base Table, alias string) string {
+	conditions := make([]string, 0)
+	for _, key := range entity.Key {
+		tableKey, _ := columnName(table, key)
+		baseKey, _ := columnName(base, key)
+		conditions = append(conditions, fmt.Sprintf("%s.%s = t0.%s", alias, quoteName(tableKey), quoteName(baseKey)))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// Whether a column is part of the entity key.
+func isKey(entity EntityConfig, name string) bool {
+	for _, key := range entity.Key {
+		if sameName(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a table has a column, following the case rules of the collation.
+func hasColumn(table Table, name string) bool {
+	_, ok := columnName(table, name)
+	return ok
+}
\ No newline at end of file
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildEntity(t *testing.T) {
+	tables := []Table{
+		{
+			name: "ASSIGNMENTM1",
+			rowCount: 10,
+			timestamp: "SYSMODTIME",
+			columns: []Column{
+				testColumn("NAME", "nvarchar", "120", "0", "0", "false"),
+				testColumn("SYSMODTIME", "datetime", "8", "23", "3", "true"),
+			},
+		},
+		{
+			name: "ASSIGNMENTM2",
+			columns: []Column{
+				testColumn("NAME", "nvarchar", "120", "0", "0", "false"),
+				testColumn("DESCRIPTION", "ntext", "16", "0", "0", "true"),
+			},
+		},
+		{
+			name: "ASSIGNMENTA1",
+			columns: []Column{
+				testColumn("NAME", "nvarchar", "120", "0", "0", "false"),
+				testColumn("RECORD_NUMBER", "int", "4", "10", "0", "false"),
+				testColumn("OPERATORS", "nvarchar", "120", "0", "0", "true"),
+			},
+		},
+	}
+
+	entity := EntityConfig{Name: "assignment", Tables: []string{"ASSIGNMENTM1", "ASSIGNMENTM2", "ASSIGNMENTA1"}, Key: []string{"NAME"}}
+	joined, err := buildEntity(entity, tables)
+	if err != nil {
+		t.Fatal("Could not build the entity", err)
+	}
+	if joined.query != "SELECT t0.[NAME],t0.[SYSMODTIME],t1.[DESCRIPTION],t2.[RECORD_NUMBER],t2.[OPERATORS] FROM [ASSIGNMENTM1] t0 LEFT JOIN [ASSIGNMENTM2] t1 ON t1.[NAME] = t0.[NAME] LEFT JOIN [ASSIGNMENTA1] t2 ON t2.[NAME] = t0.[NAME]" {
+		t.Fatal("Unexpected entity query", joined.query)
+	}
+	if len(joined.columns) != 5 || joined.rowCount != 10 || joined.timestamp != "t0.[SYSMODTIME]" {
+		t.Fatal("Entity should keep the first table's row count and watermark", joined)
+	}
+
+	entity.Aggregate = true
+	joined, _ = buildEntity(entity, tables)
+	if !strings.Contains(joined.query, "(SELECT t2.[RECORD_NUMBER],t2.[OPERATORS] FROM [ASSIGNMENTA1] t2 WHERE t2.[NAME] = t0.[NAME] ORDER BY t2.[RECORD_NUMBER] FOR JSON PATH) AS [ASSIGNMENTA1]") || strings.Contains(joined.query, "JOIN [ASSIGNMENTA1]") {
+		t.Fatal("Array table should be aggregated into a JSON column", joined.query)
+	}
+	if len(joined.columns) != 4 || joined.columns[3].name.String != "ASSIGNMENTA1" {
+		t.Fatal("Aggregated array table should be a single column", joined.columns)
+	}
+
+	_, err = buildEntity(EntityConfig{Name: "assignment", Tables: []string{"ASSIGNMENTA1", "ASSIGNMENTM1"}, Key: []string{"NAME"}}, tables)
+	if err == nil {
+		t.Fatal("An entity starting with an array table should be refused")
+	}
+}
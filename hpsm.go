@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The DESCRIPTOR column of DBDICTM1 is in the Service Manager binary format, so the
+// dbdicts are read from the XML written by hpsm/exportDbdicts.js inside Service Manager instead.
+
+// Typedef for an exported HPSM dbdict
+type dbdict struct {
+	Name string `xml:"name,attr"`
+	Fields []dbdictField `xml:"field"`
+	Tables []dbdictTable `xml:"sqltable"`
+}
+
+// Typedef for a dbdict field, mapped fields name the SQL table alias and column they are stored in
+type dbdictField struct {
+	Name string `xml:"name,attr"`
+	Level int `xml:"level,attr"`
+	Type string `xml:"type,attr"`
+	SQLTable string `xml:"sqltable,attr"`
+	SQLField string `xml:"sqlfield,attr"`
+}
+
+// Typedef for the SQL tables behind a dbdict, such as m1 for PROBSUMMARYM1
+type dbdictTable struct {
+	Alias string `xml:"alias,attr"`
+	Name string `xml:"name,attr"`
+}
+
+// Typedef for the logical field stored in a physical column
+type logicalField struct {
+	file string
+	name string
+	structure string
+	array bool
+}
+
+// Load every dbdict export in a folder, keyed by table and column under the case rules of the collation.
+func loadDbdicts(folder string) (map[string]logicalField, error) {
+	files, err := filepath.Glob(filepath.Join(folder, "*.xml"))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]logicalField)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var definition dbdict
+		err = xml.Unmarshal(content, &definition)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		for key, field := range definition.logicalFields() {
+			fields[key] = field
+		}
+	}
+
+	return fields, nil
+}
+
+// The logical fields of a dbdict, keyed by the physical table and column they are mapped to.
+func (definition dbdict) logicalFields() map[string]logicalField {
+
+	tables := make(map[string]string)
+	for _, table := range definition.Tables {
+		tables[strings.ToLower(table.Alias)] = table.Name
+	}
+
+	// The field list is flattened, so the parent structures are tracked by level.
+	// Level 0 is the descriptor of the whole record and is not part of the path.
+	parents := make([]dbdictField, 0)
+
+	fields := make(map[string]logicalField)
+	for _, field := range definition.Fields {
+		if field.Level < 1 {
+			continue
+		}
+		if len(parents) >= field.Level {
+			parents = parents[:field.Level - 1]
+		}
+
+		path := make([]string, 0)
+		array := field.Type == "array"
+		for _, parent := range parents {
+			path = append(path, parent.Name)
+			array = array || parent.Type == "array"
+		}
+
+		table, ok := tables[strings.ToLower(field.SQLTable)]
+		if ok && field.SQLField != "" {
+			key := dbdictKey(table, field.SQLField)
+
+			// Array elements repeat the name of their array, the array itself holds the mapping.
+			if _, mapped := fields[key]; !mapped {
+				fields[key] = logicalField{
+					file: definition.Name,
+					name: field.Name,
+					structure: strings.Join(path, "."),
+					array: array,
+				}
+			}
+		}
+
+		parents = append(parents, field)
+	}
+
+	return fields
+}
+
+// The key of a physical column in the dbdict fields.
+func dbdictKey(table string, column string) string {
+	return nameKey(table) + "." + nameKey(column)
+}
+
+// Add the logical names from the dbdicts to the columns of each table.
+func applyDbdicts(tables []Table, fields map[string]logicalField) {
+	for index, table := range tables {
+		mapped := 0
+		for columnIndex, column := range table.columns {
+			// Dbdicts name the table without its schema, unless it was qualified when it was mapped.
+			field, ok := fields[dbdictKey(table.name, column.name.String)]
+			if !ok {
+				field, ok = fields[dbdictKey(splitIdentity(table.name).name, column.name.String)]
+			}
+			if !ok {
+				continue
+			}
+			tables[index].columns[columnIndex].logicalName = sql.NullString{String: field.name, Valid: true}
+			tables[index].columns[columnIndex].structure = sql.NullString{String: field.structure, Valid: true}
+			tables[index].columns[columnIndex].array = sql.NullString{String: strconv.FormatBool(field.array), Valid: true}
+			mapped++
+		}
+		if mapped < len(table.columns) {
+			log.Println(fmt.Sprintf("%d of %d columns in %s have no dbdict field", len(table.columns) - mapped, len(table.columns), table.name))
+		}
+	}
+}
+
+// The header row of a table, using the logical names where the dbdict has them.
+func logicalHeader(table Table) []string {
+	header := make([]string, 0)
+	for _, column := range table.columns {
+		if column.logicalName.String != "" {
+			header = append(header, column.logicalName.String)
+		} else {
+			header = append(header, column.name.String)
+		}
+	}
+	return header
+}
@@ -0,0 +1,55 @@
+// Writes the dbdicts Metagetter reads for logical names, one XML file each.
+//
+// Add this script to the Service Manager Script Library as exportDbdicts and run
+//     system.library.exportDbdicts.exportDbdicts("C:\\dbdict", ["probsummary", "cm3r", "assignment"]);
+// from the Script Library Execute option. Leave out the names to write every dbdict.
+
+// The RAD data type numbers stored in the dbdict, only arrays and structures matter to Metagetter.
+var TYPES = { 1: "number", 2: "character", 3: "datetime", 4: "logical", 8: "array", 9: "structure" };
+
+// Escape a value for an XML attribute.
+function xmlAttribute(value) {
+	return String(value).replace(/&/g, "&amp;").replace(/"/g, "&quot;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+}
+
+// The XML for one dbdict record, with its fields in order and the SQL tables behind it.
+function dbdictXml(dbdict) {
+	var lines = ['<?xml version="1.0" encoding="UTF-8"?>', '<dbdict name="' + xmlAttribute(dbdict.name) + '">'];
+
+	for (var i = 0; i < dbdict.field.length(); i++) {
+		var field = dbdict.field[i];
+		var line = '\t<field name="' + xmlAttribute(field.name) + '" level="' + field.level + '" type="' + (TYPES[field.type] || field.type) + '"';
+
+		// Fields without a SQL mapping live in the record of their array or structure.
+		if (field["sql.field.name"] != null && field["sql.field.name"] != "") {
+			line += ' sqltable="' + xmlAttribute(field["sql.table.alias"]) + '" sqlfield="' + xmlAttribute(field["sql.field.name"]) + '"';
+		}
+		lines.push(line + '/>');
+	}
+
+	for (var j = 0; j < dbdict["sql.tables"].length(); j++) {
+		var table = dbdict["sql.tables"][j];
+		lines.push('\t<sqltable alias="' + xmlAttribute(table["sql.table.alias"]) + '" name="' + xmlAttribute(table["sql.table.name"]) + '"/>');
+	}
+
+	lines.push('</dbdict>');
+	return lines.join("\n") + "\n";
+}
+
+// Write the named dbdicts, or all of them, to a folder.
+function exportDbdicts(folder, names) {
+	var dbdict = new SCFile("dbdict", SCFILE_READONLY);
+	var query = "true";
+	if (names != null && names.length > 0) {
+		query = 'name isin {"' + names.join('", "') + '"}';
+	}
+
+	var written = 0;
+	if (dbdict.doSelect(query) == RC_SUCCESS) {
+		do {
+			writeFile(folder + "/" + dbdict.name + ".xml", "t", dbdictXml(dbdict));
+			written++;
+		} while (dbdict.getNext() == RC_SUCCESS);
+	}
+	print("Wrote " + written + " dbdicts to " + folder);
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDbdicts(t *testing.T) {
+	fields, err := loadDbdicts("testing/dbdict")
+	if err != nil {
+		t.Fatal("Could not load the dbdicts", err)
+	}
+
+	category := fields["PROBSUMMARYM1.CATEGORY"]
+	if category.file != "probsummary" || category.name != "category" || category.structure != "header" || category.array {
+		t.Fatal("Structure field was not mapped", category)
+	}
+
+	// The array holds the mapping, not its repeated element.
+	action := fields["PROBSUMMARYM2.ACTION"]
+	if action.name != "action" || action.structure != "" || !action.array {
+		t.Fatal("Array field was not mapped", action)
+	}
+
+	// Leaving a structure drops it from the path.
+	item := fields["PROBSUMMARYM1.AFFECTED_ITEM"]
+	if item.name != "affected.item" || item.structure != "" {
+		t.Fatal("Field after a structure kept the structure path", item)
+	}
+
+	tables := []Table{{
+		name: "PROBSUMMARYM1",
+		columns: []Column{
+			testColumn("LOCATION_CODE", "nvarchar", "120", "0", "0", "true"),
+			testColumn("SYSMODTIME", "datetime", "8", "23", "3", "true"),
+		},
+	}}
+	applyDbdicts(tables, fields)
+
+	if tables[0].columns[0].logicalName.String != "location.code" || tables[0].columns[0].structure.String != "middle" {
+		t.Fatal("Logical name was not added to the column", tables[0].columns[0])
+	}
+	header := logicalHeader(tables[0])
+	if header[0] != "location.code" || header[1] != "SYSMODTIME" {
+		t.Fatal("Header should fall back to the physical name", header)
+	}
+}
+
+func TestDbdictExportScript(t *testing.T) {
+	script, err := ioutil.ReadFile(filepath.Join("hpsm", "exportDbdicts.js"))
+	if err != nil {
+		t.Fatal("Could not read the export script", err)
+	}
+
+	// Every element and attribute the dbdicts are read with has to be written by the script.
+	expected := []string{"<dbdict ", "<field ", "<sqltable "}
+	for _, definition := range []interface{}{dbdict{}, dbdictField{}, dbdictTable{}} {
+		kind := reflect.TypeOf(definition)
+		for index := 0; index < kind.NumField(); index++ {
+			tag := strings.Split(kind.Field(index).Tag.Get("xml"), ",")
+			if len(tag) == 2 && tag[1] == "attr" {
+				expected = append(expected, " " + tag[0] + `="`)
+			}
+		}
+	}
+	for _, text := range expected {
+		if !strings.Contains(string(script), text) {
+			t.Error("The export script does not write", text)
+		}
+	}
+}
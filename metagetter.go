@@ -12,12 +12,15 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"encoding/base64"
+	"encoding/hex"
 	"strconv"
 	"path/filepath"
 	"strings"
 	"compress/gzip"
 	"sync"
 	"runtime"
+	"flag"
+	"sort"
 )
 
 // Define a waitgroup, to ensure all results are finished before continuing
@@ -32,12 +35,64 @@ func databaseConnectionFactory(connectionString string) (dbConnection* sql.DB) {
 	return dbConnection
 }
 
-// Main function.
+// Main function, the exit code is set once the deferred closes in run have happened.
 func main() {
+	os.Exit(run())
+}
+
+// Run the command, returning the exit code of the program.
+func run() int {
 
 	// Start timing the script.
 	start := time.Now()
 
+	// The command is the first argument, a full extract when it is left out.
+	command := "extract"
+	arguments := os.Args[1:]
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		command = arguments[0]
+		arguments = arguments[1:]
+	}
+	if command != "extract" && command != "describe" && command != "docs" {
+		log.Fatal(fmt.Sprintf("Unknown command %s, expected extract, describe or docs", command))
+	}
+
+	// Parse the command flags.
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+
+	// The docs are generated offline from the catalog of a run.
+	if command == "docs" {
+		runFlag := flags.String("run", "", "Run folder to document, the latest run in results when left out")
+		outFlag := flags.String("out", "", "Folder to write the docs to, the docs folder of the run when left out")
+		flags.Parse(arguments)
+
+		err := writeDocs(*runFlag, *outFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Program ran in %s", time.Since(start))
+		return 0
+	}
+
+	targetFlag := flags.String("target", "", "Comma separated target dialects to write describes for: " + strings.Join(dialectNames(), ", "))
+	acceptFlag := flags.Bool("accept-drift", false, "Accept the schema drift stopped by earlier runs and extract those tables again")
+	flags.Parse(arguments)
+
+	// Check the target dialects before doing any work.
+	targets := make([]Dialect, 0)
+	for _, name := range strings.Split(*targetFlag, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		dialect, ok := dialects[name]
+		if !ok {
+			log.Fatal(fmt.Sprintf("Unknown target %s, expected one of %s", name, strings.Join(dialectNames(), ", ")))
+		}
+		targets = append(targets, dialect)
+	}
+
 	// Make go use more procs
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
@@ -47,8 +102,10 @@ func main() {
 	// Filepath separator
 	sep := string(filepath.Separator)
 
-	// Delta map
+	// Delta map, with the column each delta was taken from and the rowversion watermarks kept as hex.
 	deltaMap := make(map[string]time.Time)
+	deltaColumns := make(map[string]string)
+	deltaVersions := make(map[string]string)
 
 	// Load the previous delta folder.
 	deltaDate := findPreviousDelta("results")
@@ -56,7 +113,7 @@ func main() {
 		deltaCSV, err := os.Open("results" + sep + deltaDate + sep + "delta" + sep + "delta.csv")
 		if err != nil {
 			log.Println(err)
-			return
+			return 1
 		}
 		defer deltaCSV.Close()
 
@@ -69,6 +126,11 @@ func main() {
 				}
 				log.Println(err)
 			}
+			deltaColumns[row[0]] = row[1]
+			if strings.HasPrefix(row[2], "0x") {
+				deltaVersions[row[0]] = row[2]
+				continue
+			}
 			timestamp, err := time.Parse(time.RFC3339, row[2])
 			deltaMap[row[0]] = timestamp
 		}
@@ -79,7 +141,76 @@ func main() {
 	config, err := loadConfiguration("config.json");
 	if err != nil {
 		log.Println(err)
-		return
+		return 1
+	}
+
+	// The table mode only brings in the whitelist or blacklist.
+	if config.Mode != "" && config.Mode != "whitelist" && config.Mode != "blacklist" {
+		log.Println(fmt.Sprintf("Unknown mode %s, expected whitelist or blacklist", config.Mode))
+		return 1
+	}
+
+	// Views, synonyms and functions can be extracted along with the tables.
+	for _, source := range config.Sources {
+		if source != "views" && source != "synonyms" && source != "functions" {
+			log.Println(fmt.Sprintf("Unknown source %s, expected views, synonyms or functions", source))
+			return 1
+		}
+	}
+
+	// Watermarks come from the timestamps list, or are detected when no listed column is found.
+	if config.Watermarks != "" && config.Watermarks != "list" && config.Watermarks != "auto" {
+		log.Println(fmt.Sprintf("Unknown watermarks option %s, expected list or auto", config.Watermarks))
+		return 1
+	}
+
+	// Schema drift either warns or stops the affected tables.
+	if config.Drift != "" && config.Drift != "warn" && config.Drift != "stop" {
+		log.Println(fmt.Sprintf("Unknown drift option %s, expected warn or stop", config.Drift))
+		return 1
+	}
+
+	// Profiling either samples each table or watches the rows as they are extracted.
+	if config.Profile.Mode != "" && config.Profile.Mode != "sample" && config.Profile.Mode != "stream" {
+		log.Println(fmt.Sprintf("Unknown profile mode %s, expected sample or stream", config.Profile.Mode))
+		return 1
+	}
+	if config.Profile.Distinct != "" && config.Profile.Distinct != "exact" && config.Profile.Distinct != "hll" {
+		log.Println(fmt.Sprintf("Unknown profile distinct option %s, expected exact or hll", config.Profile.Distinct))
+		return 1
+	}
+	if config.Profile.Sample == 0 {
+		config.Profile.Sample = 10000
+	}
+	if config.Profile.Top == 0 {
+		config.Profile.Top = 10
+	}
+
+	// Check the data quality rules before starting.
+	err = validateRules(config.Rules)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	// Check the per table settings before starting.
+	err = validateTableConfigs(config.Tables)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	// Row counts may move a little between counting and extracting a live table.
+	// Only the limits left out are defaulted, so 0 can be set.
+	tolerance, drop, spike := 1.0, 50.0, 100.0
+	if config.Reconcile.Tolerance == nil {
+		config.Reconcile.Tolerance = &tolerance
+	}
+	if config.Reconcile.Drop == nil {
+		config.Reconcile.Drop = &drop
+	}
+	if config.Reconcile.Spike == nil {
+		config.Reconcile.Spike = &spike
 	}
 
 	// Create the server strings needed for the connection.
@@ -115,22 +246,42 @@ func main() {
 	dbConnection := databaseConnectionFactory(conString)
 	defer dbConnection.Close()
 
+	// Get the database level information for the catalog.
+	databaseInfo := getDatabaseInfo(dbConnection)
+
+	// Config names are matched with the case rules of the database collation.
+	collationRules = newNameRules(databaseInfo.collation)
+
 	// The parent container which holds all the metadata.
 	tableContainer := make([]Table, 0)
 
-	// Create the table slice.
-	var tables []string
-
-	// Need to check the mode and include/exclude tables.
+	// The whitelist and blacklist modes are the older form of the include and exclude patterns.
+	include := config.Include
+	exclude := config.Exclude
 	switch config.Mode {
-		case "whitelist": {
-			log.Println("Using the table whitelist")
-			tables = config.Whitelist
-		}
-		case "blacklist": {
-			log.Println("Fetching the SQL table list")
-			tables = getTables(config.Database, config.Blacklist, dbConnection)
-		}
+		case "whitelist":
+			include = append(include, config.Whitelist...)
+		case "blacklist":
+			exclude = append(exclude, config.Blacklist...)
+	}
+
+	// Pick the tables out of the SQL table list.
+	log.Println("Fetching the SQL table list")
+	tables, err := selectTables(getTables(config.Sources, dbConnection), include, exclude)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	// Check the query names against the tables before starting.
+	names := make([]string, 0)
+	for _, table := range tables {
+		names = append(names, table.identity())
+	}
+	err = validateQueries(config.Queries, names)
+	if err != nil {
+		log.Println(err)
+		return 1
 	}
 
 	// Check if the results folder exists
@@ -156,228 +307,343 @@ func main() {
 		log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "metadata"))
 	}
 
-	// Create the tables folder
-	err = os.Mkdir(base + "tables", 0777)
-	if err != nil {
-		log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "tables"))
-	}
-
 	// Create the describe folder
 	err = os.Mkdir(base + "describe", 0777)
 	if err != nil {
 		log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "describe"))
 	}
 
-	// Create the tables folder
-	err = os.Mkdir(base + "delta", 0777)
+	// Create the programmability folder
+	err = os.Mkdir(base + "describe" + sep + "programmability", 0777)
 	if err != nil {
-		log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "delta"))
+		log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "describe" + sep + "programmability"))
 	}
 
-	// Loop through the table and run the queries
-	log.Println("Getting the metadata and row counts")
-	for _, table := range tables {
-		result := getTableMetadata(table, dbConnection)
-		result.rowCount = getRowCount(table, dbConnection)
-		result.folder = base + "tables"
-		tableContainer = append(tableContainer, result)
+	// Create the schemas folder
+	err = os.Mkdir(base + "schemas", 0777)
+	if err != nil {
+		log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "schemas"))
 	}
 
-	// Loop through the results and write out CSV files.
-	log.Println("Writing out the metadata to disk")
-	for _, table := range tableContainer {
-
-		// Create the CSV file handle.
-		outFile, err := os.Create(base + "metadata" + sep + table.name + ".csv")
+	// Create the profile folder
+	if config.Profile.Mode != "" {
+		err = os.Mkdir(base + "profile", 0777)
 		if err != nil {
-			fmt.Println(err)
-			return
+			log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "profile"))
 		}
-		defer outFile.Close()
-
-		// Create the CSV writer from the file handle.
-		writer := csv.NewWriter(outFile)
-
-		// Write the header row.
-		writer.Write(
-			[]string {
-				"Column Name",
-				"Data Type",
-				"Max Length",
-				"Precision",
-				"Scale",
-				"Nullable",
-				"Ordinal Position",
-				"Collation Name",
-				"Primary Key",
-				"Row Count",
-			},
-		)
-
-		// Write each column and its data out to the file.
-		for _, column := range table.columns {
-			writer.Write([]string{
-				column.name.String,
-				column.dataType.String,
-				column.maxLength.String,
-				column.precision.String,
-				column.scale.String,
-				column.nullable.String,
-				column.ordinalPosition.String,
-				column.collationName.String,
-				column.primaryKey.String,
-				strconv.Itoa(table.rowCount),
-			})
+	}
 
-			// Flush the current row out to the file.
-			writer.Flush()
+	// Create a describe folder for each target dialect
+	for _, dialect := range targets {
+		err = os.Mkdir(base + "describe" + sep + dialect.name, 0777)
+		if err != nil {
+			log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "describe" + sep + dialect.name))
 		}
 	}
 
-	// Loop through the results and write out the describe statements
-	log.Println("Writing out the describes to disk")
-	for _, table := range tableContainer {
+	// The data folders are only needed for a full extract.
+	if command == "extract" {
 
-		// Create the CSV file handle.
-		outFile, err := os.Create(base + "describe" + sep + table.name + ".sql")
+		// Create the tables folder
+		err = os.Mkdir(base + "tables", 0777)
 		if err != nil {
-			fmt.Println(err)
-			return
+			log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "tables"))
 		}
-		defer outFile.Close()
-
-		// Write the header row.
-		outFile.WriteString(fmt.Sprintf("CREATE TABLE [%s] (\n", table.name))
 
-		// Write each column and its data out to the file.
-		for _, column := range table.columns {
+		// Create the delta folder
+		err = os.Mkdir(base + "delta", 0777)
+		if err != nil {
+			log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "delta"))
+		}
 
-			var dataType string
-			switch column.dataType.String {
-				case "bigint":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "binary":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "bit":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "char":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "date":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "datetime":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "datetimeoffset":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "decimal":
-					dataType = fmt.Sprintf("[%s](%s, %s)", column.dataType.String, column.precision.String, column.scale.String)
-				case "float":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "geography":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "geometry":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "hierarchyid":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "image":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "int":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "money":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "nchar":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "ntext":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "numeric":
-					dataType = fmt.Sprintf("[%s](%s, %s)", column.dataType.String, column.precision.String, column.scale.String)
-				case "nvarchar":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "real":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "smalldatetime":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "smallint":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "smallmoney":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "sql_variant":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "text":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "time":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "timestamp":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "tinyint":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "uniqueidentifier":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				case "varbinary":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "varchar":
-					dataType = fmt.Sprintf("[%s](%s)", column.dataType.String, column.maxLength.String)
-				case "xml":
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
-				default:
-					dataType = fmt.Sprintf("[%s]", column.dataType.String)
+		// Create the quality folder
+		if len(config.Rules) > 0 {
+			err = os.Mkdir(base + "quality", 0777)
+			if err != nil {
+				log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "quality"))
 			}
+		}
 
-			var null string
-			switch column.nullable.String {
-				case "true":
-					null = "NULL"
-				case "false":
-					null = "NOT NULL"
-				default:
-					null = "NULL"
+		// Create the queries folder
+		if len(config.Queries) > 0 {
+			err = os.Mkdir(base + "queries", 0777)
+			if err != nil {
+				log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "queries"))
 			}
+		}
 
-			var primaryKey string
-			switch column.primaryKey.String {
-				case "true":
-					primaryKey = " PRIMARY KEY"
-				case "false":
-					primaryKey = ""
-				default:
-					primaryKey = ""
+		// Create the entities folder
+		if len(config.Entities) > 0 {
+			err = os.Mkdir(base + "entities", 0777)
+			if err != nil {
+				log.Println(fmt.Sprintf("Unable to create the directory: %s", base + "entities"))
 			}
+		}
+	}
 
-			outFile.WriteString(fmt.Sprintf("\t[%s] %s %s%s,\n",
-				column.name.String,
-				dataType,
-				null,
-				primaryKey,
-			))
+	// Loop through the table and run the queries
+	log.Println("Getting the metadata and row counts")
+	for _, table := range tables {
+		result := getTableMetadata(table, dbConnection)
+		if settings, ok := tableSettings(config.Tables, result.name); ok {
+			result = applyTableConfig(result, settings)
+		}
+		result.rowCount = getRowCount(result, dbConnection)
+		result.folder = base + "tables"
+		tableContainer = append(tableContainer, result)
+	}
+
+	// Add the HPSM logical field names from the dbdict exports.
+	if config.Hpsm.Dbdict != "" {
+		log.Println("Loading the HPSM dbdicts")
+		fields, err := loadDbdicts(config.Hpsm.Dbdict)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		applyDbdicts(tableContainer, fields)
+
+		if config.Hpsm.LogicalHeaders {
+			for index, table := range tableContainer {
+				tableContainer[index].header = logicalHeader(table)
+			}
 		}
+	}
 
-		outFile.WriteString(");")
+	// Read the columns of each named query from its result set.
+	queryTables := make([]Table, 0)
+	for _, queryConfig := range config.Queries {
+		result, err := getQueryMetadata(queryConfig, dbConnection)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		result.folder = base + "queries"
+		if settings, ok := tableSettings(config.Tables, result.name); ok {
+			applyOutputConfig(&result, settings)
+		}
+		queryTables = append(queryTables, result)
 	}
 
 	// Determine if the table is a TYPE 2 or not.
 	log.Println("Type 2 Tables")
 	for index, table := range tableContainer {
 		for _, type2 := range config.Type2 {
-			if strings.ToUpper(table.name) == type2 {
+			if sameName(type2, table.name) {
 				tableContainer[index].type2 = true
 				log.Println(table.name)
 			}
 		}
 	}
 
-	// Determine the delta which is used in the name, from the config heirarchy.
-	// Don't bother if its a known type 2 table.
+	// Pick the watermark each delta is taken from, type 2 tables are always loaded in full.
+	log.Println("Watermarks")
 	for index, table := range tableContainer {
-		if !table.type2 {
-			for _, column := range table.columns {
-				for _, timestamp := range config.Timestamps {
-					if strings.ToUpper(column.name.String) == timestamp {
-						tableContainer[index].timestamp = strings.ToUpper(column.name.String)
+		settings, _ := tableSettings(config.Tables, table.name)
+		choice := chooseWatermark(table, config.Timestamps, settings.Watermark, config.Watermarks == "auto")
+		tableContainer[index].timestamp = choice.column
+		tableContainer[index].watermarkStrategy = choice.strategy
+		tableContainer[index].watermarkReason = choice.reason
+		if choice.column != "" {
+			log.Println(fmt.Sprintf("%s: %s (%s, %s)", table.name, choice.column, choice.strategy, choice.reason))
+		} else {
+			log.Println(fmt.Sprintf("%s: full load (%s, %s)", table.name, choice.strategy, choice.reason))
+		}
+	}
+
+	// Report the config entries which match no table or column.
+	tableNames := make([]string, 0)
+	columnNames := make([]string, 0)
+	for _, table := range tableContainer {
+		tableNames = append(tableNames, table.name)
+		for _, column := range table.columns {
+			columnNames = append(columnNames, column.name.String)
+		}
+	}
+	reportUnmatched("type2", config.Type2, tableNames)
+	reportUnmatched("timestamps", config.Timestamps, columnNames)
+
+	ruleTables := make([]string, 0)
+	for _, rule := range config.Rules {
+		ruleTables = append(ruleTables, rule.Table)
+	}
+	reportUnmatched("rules", ruleTables, tableNames)
+
+	settingNames := make([]string, 0)
+	for name := range config.Tables {
+		settingNames = append(settingNames, name)
+	}
+	sort.Strings(settingNames)
+	outputNames := append(make([]string, 0), tableNames...)
+	for _, table := range queryTables {
+		outputNames = append(outputNames, table.name)
+	}
+	for _, entityConfig := range config.Entities {
+		outputNames = append(outputNames, entityConfig.Name)
+	}
+	reportUnmatched("tables", settingNames, outputNames)
+
+	// Build the joined tables for the logical entities.
+	entities := make([]Table, 0)
+	for _, entityConfig := range config.Entities {
+		entity, err := buildEntity(entityConfig, tableContainer)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		entity.folder = base + "entities"
+		if settings, ok := tableSettings(config.Tables, entity.name); ok {
+			applyOutputConfig(&entity, settings)
+		}
+
+		// The entities follow the delta of their first table.
+		first, _ := resolveName(entityConfig.Tables[0], tableNames)
+		for _, table := range tableContainer {
+			if table.name == first {
+				entity.where = deltaSince(table, deltaMap, deltaColumns, deltaVersions)
+			}
+		}
+		if config.Hpsm.LogicalHeaders {
+			entity.header = logicalHeader(entity)
+		}
+		entities = append(entities, entity)
+	}
+
+	// Loop through the results and write out CSV files.
+	log.Println("Writing out the metadata to disk")
+	for _, table := range tableContainer {
+		writeMetadata(table, base + "metadata")
+
+		// Write the table constraints and indexes out to their own files.
+		writeConstraints(table, base + "metadata")
+		writeIndexes(table, base + "metadata")
+	}
+	for _, table := range append(queryTables, entities...) {
+		writeMetadata(table, base + "metadata")
+	}
+
+	// Profile a sample of each table, streaming is only possible while extracting.
+	if config.Profile.Mode == "sample" || (config.Profile.Mode == "stream" && command != "extract") {
+		log.Println("Profiling a sample of each table")
+		for _, table := range tableContainer {
+			if table.rowCount > 0 {
+				profiler := newTableProfiler(table, config.Profile, "sample", base + "profile" + sep + table.name + ".json")
+				profileSample(table, profiler, config.Profile.Sample, dbConnection)
+			}
+		}
+	}
+
+	// Write out the describe statements
+	log.Println("Writing out the describes to disk")
+	writeDescribes(tableContainer, base + "describe")
+	writeDescribes(queryTables, base + "describe")
+	writeDescribes(entities, base + "describe")
+
+	// Everything the run writes out, the tables first and then the queries and entities.
+	outputs := append(append(append(make([]Table, 0), tableContainer...), queryTables...), entities...)
+
+	// Write out the describe statements for each target dialect
+	for _, dialect := range targets {
+		log.Println(fmt.Sprintf("Writing out the %s describes to disk", dialect.name))
+		writeTargetDescribes(outputs, dialect, base + "describe" + sep + dialect.name)
+	}
+
+	// Write out the procedures, functions and triggers, along with what changed since the previous run.
+	log.Println("Writing out the programmability to disk")
+	programmability := "describe" + sep + "programmability"
+	scripts := writeModules(getModules(dbConnection), base + programmability)
+	previousModules := findPreviousRun("results", programmability + sep + "changes.json")
+	var previousFolder string
+	if previousModules != "" {
+		previousFolder = "results" + sep + previousModules + sep + programmability
+	}
+	moduleChanges := compareModules(previousModules, previousFolder, scripts)
+	if len(moduleChanges.Added) + len(moduleChanges.Removed) + len(moduleChanges.Changed) > 0 {
+		log.Println(fmt.Sprintf("Programmability changed since %s: %d added, %d removed, %d changed",
+			previousModules,
+			len(moduleChanges.Added),
+			len(moduleChanges.Removed),
+			len(moduleChanges.Changed),
+		))
+	}
+	writeJSON(base + programmability + sep + "changes.json", moduleChanges)
+
+	// Write out the JSON Schema, Avro and Spark schemas
+	log.Println("Writing out the schemas to disk")
+	writeSchemas(tableContainer, base + "schemas", config.Database)
+	writeSchemas(queryTables, base + "schemas", config.Database)
+	writeSchemas(entities, base + "schemas", config.Database)
+
+	// Build the catalog of everything this run produces
+	catalog := buildCatalog(config, databaseInfo, outputs, targets, command == "extract")
+
+	// The state keeps the row counts and the drift which has not been accepted yet.
+	state, err := loadState("results" + sep + "state.json")
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if *acceptFlag {
+		log.Println("Accepting the schema drift of earlier runs")
+		state.Drift = make(map[string]string)
+	}
+
+	// Compare the schema with the previous run which extracted, describe only runs are not a baseline.
+	previousRun := findPreviousRun("results", "catalog.json", "manifest.json")
+	if previousRun != "" {
+		changes, err := trackDrift("results", previousRun, catalog, state, config.Drift == "stop")
+		if err != nil {
+			log.Println(err)
+		} else {
+			log.Println("Comparing the schema with the previous run")
+			writeSchemaChanges(changes, base)
+
+			if changes.any() {
+				log.Println(changes.report())
+
+				// Write out the scripts which move the targets to the new schema
+				log.Println("Writing out the migrations to disk")
+				writeMigrations(changes, outputs, base + "migrations")
+			}
+
+			// Stop extracting the tables whose columns changed, until the drift is accepted.
+			if config.Drift == "stop" {
+				affected := changes.affected()
+
+				// Entities stop along with any of their tables.
+				for _, entityConfig := range config.Entities {
+					for _, name := range entityConfig.Tables {
+						if table, ok := resolveName(name, tableNames); ok && affected[table] {
+							affected[entityConfig.Name] = true
+						}
 					}
 				}
+
+				offset := 0
+				for _, tables := range [][]Table{tableContainer, queryTables, entities} {
+					for index, table := range tables {
+						if affected[table.name] {
+							log.Println(fmt.Sprintf("Schema drift on %s, the table will not be extracted", table.name))
+							tables[index].drifted = true
+							catalog.Tables[offset + index].Files.Data = ""
+						}
+					}
+					offset += len(tables)
+				}
 			}
 		}
 	}
 
+	// Write out the catalog
+	log.Println("Writing out the catalog to disk")
+	writeJSON(base + "catalog.json", catalog)
+
+	// The describe command stops once the metadata is written.
+	if command == "describe" {
+		fmt.Printf("Program ran in %s", time.Since(start))
+		return 0
+	}
+
 	// Loop through the tables and generate the actual data.
 	log.Println("Writing out the deltas")
 	writeDeltas(tableContainer, base + "delta", dbConnection)
@@ -393,23 +659,96 @@ func main() {
 	// Loop through the tables and generate the actual data.
 	log.Println("Starting the data download")
 	for _, table := range tableContainer {
-		if table.rowCount > 0 {
-			deltaTime := deltaMap[table.name]
-			if deltaTime.IsZero() || table.type2 {
-				table.where = ""
-			} else {
-				table.where = deltaTime.Format(time.RFC3339)
+
+		// Freshness is checked against the table, the other rules against the rows as they are written.
+		var checker *qualityChecker
+		rules := tableRules(config.Rules, table.name)
+		if len(rules) > 0 {
+			checker = newQualityChecker(table, rules, base + "quality" + sep + table.name + ".json")
+			checker.checkFreshness(table, dbConnection)
+		}
+
+		if table.rowCount > 0 && !table.drifted {
+			table.where = deltaSince(table, deltaMap, deltaColumns, deltaVersions)
+
+			// Profile the rows as they go past, saving a second scan.
+			if config.Profile.Mode == "stream" {
+				table.observers = append(table.observers, newTableProfiler(table, config.Profile, "stream", base + "profile" + sep + table.name + ".json"))
+			}
+			if checker != nil {
+				table.observers = append(table.observers, checker)
 			}
 			inputChannel <- table
+		} else if checker != nil {
+			checker.finish(nil)
 		}
 	}
+	for _, entity := range entities {
+		if entity.rowCount > 0 && !entity.drifted {
+			inputChannel <- entity
+		}
+	}
+
+	// The queries are always run unless they drifted, continuing from their own delta when they have a watermark.
+	for index, queryTable := range queryTables {
+		if queryTable.drifted {
+			continue
+		}
+		deltaTime := deltaMap[queryTable.name]
+		if queryTable.timestamp != "" && !deltaTime.IsZero() {
+			queryTable.where = deltaTime.Format(time.RFC3339)
+		}
+		for _, queryConfig := range config.Queries {
+			if queryConfig.Name == queryTable.name {
+				queryTable.parameters = queryParameters(queryConfig, deltaTime)
+			}
+		}
+		queryTables[index] = queryTable
+		inputChannel <- queryTable
+	}
 	close(inputChannel)
 
 	// Wait for all addresses to resolve
 	waitGroup.Wait()
 
+	// Write out the manifest of what was extracted
+	log.Println("Writing out the manifest to disk")
+	writeManifest(base + "manifest.json")
+
+	// Record where the queries with a watermark got to
+	appendQueryDeltas(queryTables, manifest.Tables, deltaMap, base + "delta")
+
+	// Reconcile the row counts, and look for sudden changes since the last run.
+	flagged := reconcile(tableContainer, manifest.Tables, state, timeFol, config.Reconcile)
+	flagged = append(flagged, qualityFlags(qualityReports)...)
+	for _, problem := range flagged {
+		log.Println(problem.Message)
+	}
+	state.record(timeFol, tableContainer)
+	writeJSON("results" + sep + "state.json", state)
+
+	// Write out the run summary
+	summary := Summary{
+		Run: timeFol,
+		Command: command,
+		Started: start,
+		Finished: time.Now(),
+		Tables: len(manifest.Tables),
+		Flags: flagged,
+	}
+	for _, entry := range manifest.Tables {
+		summary.Rows += entry.Rows
+	}
+	writeJSON(base + "summary.json", summary)
+
 	// Print the final time the program ran.
 	fmt.Printf("Program ran in %s", time.Since(start))
+
+	// Fail the run when anything was flagged, so the scheduler notices.
+	if len(flagged) > 0 {
+		return 1
+	}
+	return 0
 }
 
 func loadConfiguration(path string) (*Config, error) {
@@ -427,6 +766,207 @@ func loadConfiguration(path string) (*Config, error) {
 	return &config, nil
 }
 
+func writeMetadata(table Table, folder string) {
+
+	// Create the CSV file handle.
+	outFile, err := os.Create(folder + string(filepath.Separator) + table.name + ".csv")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer outFile.Close()
+
+	// Create the CSV writer from the file handle.
+	writer := csv.NewWriter(outFile)
+
+	// Write the header row.
+	writer.Write(
+		[]string {
+			"Column Name",
+			"Data Type",
+			"Max Length",
+			"Precision",
+			"Scale",
+			"Nullable",
+			"Ordinal Position",
+			"Collation Name",
+			"Primary Key",
+			"Row Count",
+			"Default",
+			"Identity Seed",
+			"Identity Increment",
+			"Computed Definition",
+			"Description",
+			"Logical Name",
+			"Structure",
+			"Array",
+			"Watermark",
+			"Watermark Strategy",
+			"Watermark Reason",
+		},
+	)
+
+	// Write each column and its data out to the file.
+	for _, column := range table.columns {
+		writer.Write([]string{
+			column.name.String,
+			column.dataType.String,
+			column.maxLength.String,
+			column.precision.String,
+			column.scale.String,
+			column.nullable.String,
+			column.ordinalPosition.String,
+			column.collationName.String,
+			column.primaryKey.String,
+			strconv.Itoa(table.rowCount),
+			column.defaultDefinition.String,
+			column.identitySeed.String,
+			column.identityIncrement.String,
+			column.computedDefinition.String,
+			column.description.String,
+			column.logicalName.String,
+			column.structure.String,
+			column.array.String,
+			table.timestamp,
+			table.watermarkStrategy,
+			table.watermarkReason,
+		})
+
+		// Flush the current row out to the file.
+		writer.Flush()
+	}
+}
+
+func writeConstraints(table Table, folder string) {
+
+	// Create the CSV file handle.
+	outFile, err := os.Create(folder + string(filepath.Separator) + table.name + "_constraints.csv")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer outFile.Close()
+
+	// Create the CSV writer from the file handle.
+	writer := csv.NewWriter(outFile)
+
+	// Write the header row.
+	writer.Write(
+		[]string{
+			"Constraint Name",
+			"Constraint Type",
+			"Columns",
+			"Referenced Table",
+			"Referenced Columns",
+			"Definition",
+		},
+	)
+
+	for _, column := range table.columns {
+		if column.defaultDefinition.Valid {
+			writer.Write([]string{
+				column.defaultName.String,
+				"DEFAULT",
+				column.name.String,
+				"",
+				"",
+				column.defaultDefinition.String,
+			})
+		}
+	}
+
+	for _, unique := range table.uniqueConstraints {
+		writer.Write([]string{
+			unique.name,
+			"UNIQUE",
+			strings.Join(unique.columns, ", "),
+			"",
+			"",
+			"",
+		})
+	}
+
+	for _, check := range table.checkConstraints {
+		writer.Write([]string{
+			check.name,
+			"CHECK",
+			check.column,
+			"",
+			"",
+			check.definition,
+		})
+	}
+
+	for _, foreignKey := range table.foreignKeys {
+		writer.Write([]string{
+			foreignKey.name,
+			"FOREIGN KEY",
+			strings.Join(foreignKey.columns, ", "),
+			foreignKey.referencedTable,
+			strings.Join(foreignKey.referencedColumns, ", "),
+			fmt.Sprintf("ON DELETE %s ON UPDATE %s", foreignKey.deleteAction, foreignKey.updateAction),
+		})
+	}
+
+	// Flush the rows out to the file.
+	writer.Flush()
+}
+
+func writeIndexes(table Table, folder string) {
+
+	// Create the CSV file handle.
+	outFile, err := os.Create(folder + string(filepath.Separator) + table.name + "_indexes.csv")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer outFile.Close()
+
+	// Create the CSV writer from the file handle.
+	writer := csv.NewWriter(outFile)
+
+	// Write the header row.
+	writer.Write(
+		[]string{
+			"Index Name",
+			"Index Type",
+			"Clustered",
+			"Unique",
+			"Primary Key",
+			"Key Columns",
+			"Included Columns",
+			"Filter Definition",
+		},
+	)
+
+	for _, index := range table.indexes {
+
+		// Key columns keep their order and sort direction.
+		keyColumns := make([]string, 0)
+		for _, column := range index.keyColumns {
+			if column.descending {
+				keyColumns = append(keyColumns, column.name + " DESC")
+			} else {
+				keyColumns = append(keyColumns, column.name)
+			}
+		}
+
+		writer.Write([]string{
+			index.name,
+			index.indexType,
+			strconv.FormatBool(index.clustered),
+			strconv.FormatBool(index.unique),
+			strconv.FormatBool(index.primaryKey),
+			strings.Join(keyColumns, ", "),
+			strings.Join(index.includedColumns, ", "),
+			index.filterDefinition,
+		})
+	}
+
+	// Flush the rows out to the file.
+	writer.Flush()
+}
+
 func writeDeltas(tables []Table, folder string, dbConnection* sql.DB) {
 
 	// Create the CSV file handle.
@@ -453,14 +993,21 @@ func writeDeltas(tables []Table, folder string, dbConnection* sql.DB) {
 
 	for _, table := range tables {
 
-		if table.rowCount == 0 {
+		if table.rowCount == 0 || table.drifted {
 			continue
 		}
 
 		if table.timestamp != "" {
-			timestamp := getMaxTimestamp(table.name, table.timestamp, dbConnection)
 
-			if timestamp.IsZero() {
+			// Rowversions are written as hex, datetimes as RFC 3339.
+			var latest string
+			if rowversionWatermark(table) {
+				latest = getMaxRowversion(table, table.timestamp, dbConnection)
+			} else if timestamp := getMaxTimestamp(table, table.timestamp, dbConnection); !timestamp.IsZero() {
+				latest = timestamp.Format(time.RFC3339)
+			}
+
+			if latest == "" {
 				continue
 			}
 
@@ -468,7 +1015,7 @@ func writeDeltas(tables []Table, folder string, dbConnection* sql.DB) {
 			writer.Write([]string{
 				table.name,
 				table.timestamp,
-				timestamp.Format(time.RFC3339),
+				latest,
 				strconv.Itoa(table.rowCount),
 			})
 
@@ -492,59 +1039,44 @@ func getTableData(tables <-chan Table, conString string, worker int) {
 		// DB Connection Object
 		dbConnection := databaseConnectionFactory(conString);
 
-		// Build select order
-		var columnList string
-		for index, column := range table.columns {
-			var columnName string
-
-			// Dealing with the service manager "image" types, which are actually binary data we can't read yet.
-			if column.dataType.String == "image" {
-				columnName = "'{img}' as [" + column.name.String + "]"
-			} else {
-				columnName = "[" + column.name.String + "]"
-			}
-
-			if index == 0 {
-				columnList += columnName
-			} else {
-				columnList += "," + columnName
-			}
-		}
-
-		where := ""
-		if table.where != "" {
-			where = fmt.Sprintf("WHERE %s >= '%s'", table.timestamp, table.where)
-			log.Println(where)
-		}
-
-		// Final query string for getting the database values.
-		queryString := fmt.Sprintf("SELECT %s FROM [%s] %s", columnList, table.name, where)
-
 		// Open the database query and get ready to read results.
-		query, err := dbConnection.Query(queryString)
+		queryString, parameters := extractQuery(table)
+		query, err := dbConnection.Query(queryString, parameters...)
 		if err != nil {
 			log.Fatal(err)
 			break
 		}
 		defer query.Close()
 
-		// Create the CSV file handle.
-		outFile, err := os.Create(table.folder + string(filepath.Separator) + table.name + ".csv.gz")
+		// Create the output file handle.
+		outFile, err := os.Create(table.folder + string(filepath.Separator) + dataFile(table))
 		if err != nil {
 			log.Fatal(err)
 			break
 		}
 		defer outFile.Close()
 
-		// Create the GZIP file handle.
-		gzwriter, err := gzip.NewWriterLevel(outFile, gzip.DefaultCompression)
+		// Create the GZIP file handle, unless the table is written uncompressed.
+		var out io.Writer = outFile
+		var gzwriter *gzip.Writer
+		if table.compression != "none" {
+			gzwriter, err = gzip.NewWriterLevel(outFile, gzip.DefaultCompression)
+			if err != nil {
+				log.Fatal(err)
+				break
+			}
+			out = gzwriter
+		}
+
+		// Create the writer for the table's format from the file handle.
+		writer, err := newRowWriter(out, table)
 		if err != nil {
 			log.Fatal(err)
 			break
 		}
 
-		// Create the CSV writer from the file handle.
-		writer := csv.NewWriter(gzwriter)
+		// Watch the rows as they are written, for the manifest.
+		observers := tableObservers(table)
 
 		// Go through the results and create an array of results.
 		for query.Next() {
@@ -568,42 +1100,34 @@ func getTableData(tables <-chan Table, conString string, worker int) {
 			}
 
 			// Loop through the interface and double dereference the interfaces to check the type.
+			values := make([]interface{}, 0)
 			for i := 0; i < len(table.columns); i++ {
+				value := *dataInterface[i].(*interface{})
+				values = append(values, value)
+				data = append(data, formatValue(value))
+			}
 
-				var r string
-				switch v := (*dataInterface[i].(*interface{})).(type) {
-					case time.Time:
-						r = v.Format(time.RFC3339)
-					case nil:
-						r = ""
-					case float64:
-						r = fmt.Sprintf("%.2f", v)
-					case int:
-						r = fmt.Sprintf("%v", v)
-					case int8:
-						r = fmt.Sprintf("%v", v)
-					case int16:
-						r = fmt.Sprintf("%v", v)
-					case int32:
-						r = fmt.Sprintf("%v", v)
-					case int64:
-						r = fmt.Sprintf("%v", v)
-					case []byte:
-						r = string(v)
-					default:
-						if str, ok := v.(string); ok {
-							r = str
-						} else {
-							r = "<unknown type>"
-						}
-				}
+			for _, observer := range observers {
+				observer.observe(values, data)
+			}
+
+			writer.write(values, data)
+			writer.flush()
+		}
 
-				data = append(data, r)
+		// Finish the compressed stream.
+		if gzwriter != nil {
+			err = gzwriter.Close()
+			if err != nil {
+				log.Println(err)
 			}
+		}
 
-			writer.Write(data)
-			writer.Flush()
+		entry := newManifestEntry(table)
+		for _, observer := range observers {
+			observer.finish(&entry)
 		}
+		addManifestEntry(entry)
 
 		// Force close this connection to get another one from the pool.
 		dbConnection.Close()
@@ -614,6 +1138,101 @@ func getTableData(tables <-chan Table, conString string, worker int) {
 	waitGroup.Done()
 }
 
+// Format a scanned value for the CSV output.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+		case time.Time:
+			return v.Format(time.RFC3339)
+		case nil:
+			return ""
+		case float64:
+			return fmt.Sprintf("%.2f", v)
+		case bool:
+			return fmt.Sprintf("%v", v)
+		case int:
+			return fmt.Sprintf("%v", v)
+		case int8:
+			return fmt.Sprintf("%v", v)
+		case int16:
+			return fmt.Sprintf("%v", v)
+		case int32:
+			return fmt.Sprintf("%v", v)
+		case int64:
+			return fmt.Sprintf("%v", v)
+		case []byte:
+			return string(v)
+		default:
+			if str, ok := v.(string); ok {
+				return str
+			}
+			return "<unknown type>"
+	}
+}
+
+// Build the extract query of a table along with its parameters.
+func extractQuery(table Table) (string, []interface{}) {
+
+	// Entities and queries bring their own query, plain tables select every column.
+	queryString := table.query
+	if queryString == "" {
+		queryString = fmt.Sprintf("SELECT %s FROM %s", selectList(table.columns, ""), table.sqlName())
+	}
+	parameters := append([]interface{}{}, table.parameters...)
+
+	// The static filter from the table settings applies to deltas and full loads.
+	conditions := make([]string, 0)
+	if table.filter != "" {
+		conditions = append(conditions, "(" + table.filter + ")")
+	}
+
+	// Queries are handed their delta as the @watermark parameter instead.
+	// Rowversions are unique, so only the later ones are read again.
+	if table.where != "" && table.kind != "query" {
+		if version, err := hex.DecodeString(strings.TrimPrefix(table.where, "0x")); strings.HasPrefix(table.where, "0x") && err == nil {
+			conditions = append(conditions, watermarkExpression(table) + " > @since")
+			parameters = append(parameters, sql.Named("since", version))
+		} else {
+			conditions = append(conditions, watermarkExpression(table) + " >= @since")
+			parameters = append(parameters, sql.Named("since", table.where))
+		}
+	}
+	if len(conditions) > 0 {
+		where := "WHERE " + strings.Join(conditions, " AND ")
+		log.Println(where)
+		queryString += " " + where
+	}
+
+	return queryString, parameters
+}
+
+// The watermark column of a table, entities already qualify theirs with the alias of their first table.
+func watermarkExpression(table Table) string {
+	if table.kind == "entity" {
+		return table.timestamp
+	}
+	return quoteName(table.timestamp)
+}
+
+// The select list for a set of columns, prefixed with a table alias when one is given.
+func selectList(columns []Column, alias string) string {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+
+	list := make([]string, 0)
+	for _, column := range columns {
+
+		// Dealing with the service manager "image" types, which are actually binary data we can't read yet.
+		if column.dataType.String == "image" {
+			list = append(list, "'{img}' as " + quoteName(column.name.String))
+		} else {
+			list = append(list, prefix + quoteName(column.name.String))
+		}
+	}
+	return strings.Join(list, ",")
+}
+
 // If a folder does not exist, create it.
 func createFolder(path string) (bool, error) {
 	folderExists, err := exists(path)
@@ -643,50 +1262,50 @@ func exists(path string) (bool, error) {
 
 // Find the last delta csv
 func findPreviousDelta(path string) string {
-	folders, _ := ioutil.ReadDir(path)
-
-	if len(folders) == 0 {
-		return ""
-	}
+	return findPreviousRun(path, "delta" + string(filepath.Separator) + "delta.csv")
+}
 
-	dates := make([]time.Time, 0)
-	for _, item := range folders {
-		date, _ := time.Parse("2006_01_02", item.Name())
-		dates = append(dates, date)
-	}
+// Find the most recent run folder before today which holds all the given files.
+func findPreviousRun(path string, files ...string) string {
 
-	log.Println(dates);
+	// Runs from today are still being written, so only look at earlier days.
+	return findRun(path, time.Now().Local().Format("2006_01_02"), files...)
+}
 
-	// Get today
-	now := time.Now()
+// Find the most recent run folder which holds all the given files, only looking before a date folder when one is given.
+func findRun(path string, before string, files ...string) string {
+	folders, _ := ioutil.ReadDir(path)
 
 	// The final date
 	var folder time.Time
-	breakout := false
-
-	// Loop through the dates and find the closest to
-	i := -1
-	for {
-		// log.Println(fmt.Sprintf("Checking %s", now.AddDate(0, 0, i).Format("2006-01-02")))
-		for _, date := range dates {
-			if breakout {
-				break
-			}
-			if now.AddDate(0, 0, i).Year() == date.Year() && 
-			now.AddDate(0, 0, i).Month() == date.Month() &&
-			now.AddDate(0, 0, i).Day() == date.Day() {
-				folder = date
-				breakout = true
+
+	for _, item := range folders {
+		date, err := time.Parse("2006_01_02", item.Name())
+		if err != nil || !item.IsDir() || (before != "" && item.Name() >= before) {
+			continue
+		}
+
+		// Skip runs which did not write the files, such as describe only runs.
+		found := true
+		for _, file := range files {
+			if ok, _ := exists(path + string(filepath.Separator) + item.Name() + string(filepath.Separator) + file); !ok {
+				found = false
 			}
 		}
-		if breakout {
-			break
+		if !found {
+			continue
 		}
-		i = i - 1
+
+		if date.After(folder) {
+			folder = date
+		}
+	}
+
+	if folder.IsZero() {
+		return ""
 	}
 
-	log.Println("The oldest folder is:")
-	log.Println(folder.Format("2006_01_02"))
+	log.Println(fmt.Sprintf("The previous run folder with %s is %s", strings.Join(files, " and "), folder.Format("2006_01_02")))
 
 	return folder.Format("2006_01_02")
 }
\ No newline at end of file
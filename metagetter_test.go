@@ -1,9 +1,12 @@
 package main
 
 import (
+	"database/sql"
 	"testing"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 func TestLoadMalformedConfiguration(t *testing.T) {
@@ -80,6 +83,35 @@ func TestSingleIncorrectFolderSearch(t *testing.T) {
 	return
 }
 
+func TestPreviousRunFolderSearch(t *testing.T) {
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	// Two earlier runs with a catalog, an earlier run without one and a run from today.
+	runs := map[string]bool{
+		time.Now().AddDate(0, 0, -7).Format("2006_01_02"): true,
+		time.Now().AddDate(0, 0, -2).Format("2006_01_02"): true,
+		time.Now().AddDate(0, 0, -1).Format("2006_01_02"): false,
+		time.Now().Format("2006_01_02"): true,
+	}
+	for run, catalog := range runs {
+		os.Mkdir(filepath.Join(directory, run), 0777)
+		if catalog {
+			ioutil.WriteFile(filepath.Join(directory, run, "catalog.json"), []byte("{}"), 0666)
+		}
+	}
+
+	previous := findPreviousRun(directory, "catalog.json")
+	if previous != time.Now().AddDate(0, 0, -2).Format("2006_01_02") {
+		t.Fatal("Found the wrong previous run", previous)
+	}
+
+	return
+}
+
 /*
 func TestStub(t *testing.T) {
 	t.Error("This failed")
@@ -94,4 +126,38 @@ func TestCreateFolder(t *testing.T) {
 		t.Error("Could not create folder", path, "because", err.Error())
 	}
 }
-*/
\ No newline at end of file
+*/
+
+func TestExtractQuery(t *testing.T) {
+	table := Table{
+		name: "sales.Order Lines]",
+		schema: "sales",
+		timestamp: "Last 'Modified'",
+		where: "2018-07-01T00:00:00Z'; DROP TABLE x; --",
+		filter: "[it's] = 1",
+		columns: []Column{
+			testColumn("Unit [Price]", "money", "8", "19", "4", "true"),
+			testColumn("it's", "int", "4", "10", "0", "true"),
+			testColumn("Last 'Modified'", "datetime", "8", "23", "3", "true"),
+		},
+	}
+
+	queryString, parameters := extractQuery(table)
+	expected := "SELECT [Unit [Price]]],[it's],[Last 'Modified'] FROM [sales].[Order Lines]]] WHERE ([it's] = 1) AND [Last 'Modified'] >= @since"
+	if queryString != expected {
+		t.Fatal("Unexpected extract query", queryString)
+	}
+	if len(parameters) != 1 || parameters[0].(sql.NamedArg).Name != "since" || parameters[0].(sql.NamedArg).Value != table.where {
+		t.Fatal("The delta should be a parameter", parameters)
+	}
+
+	// Queries keep their own parameters and take the delta as @watermark.
+	table.kind = "query"
+	table.query = "SELECT 1"
+	table.filter = ""
+	table.parameters = []interface{}{sql.Named("watermark", table.where)}
+	queryString, parameters = extractQuery(table)
+	if queryString != "SELECT 1" || len(parameters) != 1 {
+		t.Fatal("Unexpected query extract", queryString, parameters)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func writeMigrations(changes SchemaChanges, tables []Table, folder string) {
+	sep := string(filepath.Separator)
+
+	// Added tables are created in full from the current metadata.
+	current := make(map[string]Table)
+	for _, table := range tables {
+		current[table.name] = table
+	}
+
+	scripts := map[string]map[string][]string{
+		"sqlserver": make(map[string][]string),
+		"postgres": make(map[string][]string),
+	}
+
+	for _, name := range changes.AddedTables {
+		table := current[name]
+		definition, unmapped := dialects["postgres"].definition(table)
+		for _, column := range unmapped {
+			log.Println(fmt.Sprintf("No postgres mapping for %s.%s of type %s", name, column.name.String, column.dataType.String))
+		}
+		scripts["sqlserver"][name] = []string{sqlServerDefinition(table).String()}
+		scripts["postgres"][name] = []string{definition.String()}
+	}
+
+	for _, name := range changes.RemovedTables {
+		scripts["sqlserver"][name] = []string{
+			fmt.Sprintf("-- WARNING: %s was removed from the source", name),
+			fmt.Sprintf("-- DROP TABLE %s;", scriptName(name)),
+		}
+		scripts["postgres"][name] = []string{
+			fmt.Sprintf("-- WARNING: %s was removed from the source", name),
+			fmt.Sprintf("-- DROP TABLE %s;", dialects["postgres"].tableName(name)),
+		}
+	}
+
+	for _, table := range changes.Tables {
+		scripts["sqlserver"][table.Name] = sqlServerMigration(table)
+		scripts["postgres"][table.Name] = postgresMigration(table)
+	}
+
+	_, err := createFolder(folder)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for dialect, tableScripts := range scripts {
+		if len(tableScripts) == 0 {
+			continue
+		}
+
+		_, err := createFolder(folder + sep + dialect)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		for name, statements := range tableScripts {
+			content := fmt.Sprintf("-- Migration for %s from the schema of %s\n", name, changes.Previous)
+			content += strings.Join(statements, "\n") + "\n"
+
+			err := ioutil.WriteFile(folder + sep + dialect + sep + name + ".sql", []byte(content), 0666)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// ALTER TABLE statements bringing a SQL Server table in line with the current columns.
+func sqlServerMigration(changes TableChanges) []string {
+
+	table := scriptName(changes.Name)
+	statements := make([]string, 0)
+
+	for _, catalogColumn := range changes.AddedColumns {
+		column := catalogColumn.column()
+
+		if catalogColumn.Computed != "" {
+			statements = append(statements, sqlServerComputed(table, catalogColumn))
+			continue
+		}
+
+		var defaultValue string
+		if catalogColumn.Default != "" {
+			defaultValue = fmt.Sprintf(" CONSTRAINT %s DEFAULT %s", quoteName(catalogColumn.DefaultName), catalogColumn.Default)
+		} else if !catalogColumn.Nullable {
+			statements = append(statements, fmt.Sprintf("-- WARNING: %s is NOT NULL without a default, this fails if the table has rows", catalogColumn.Name))
+		}
+
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s %s %s%s;",
+			table,
+			quoteName(catalogColumn.Name),
+			sqlServerType(column),
+			sqlServerNull(catalogColumn),
+			defaultValue,
+		))
+	}
+
+	for _, change := range changes.ChangedColumns {
+		if onlyPrimaryKeyChanged(change) {
+			statements = append(statements, fmt.Sprintf("-- WARNING: %s changed its primary key membership, review the key constraint", change.Name))
+			continue
+		}
+
+		// ALTER COLUMN cannot change a computed column, so it is dropped and added again.
+		if change.Previous.Computed != "" || change.Current.Computed != "" {
+			statements = append(statements, fmt.Sprintf("-- WARNING: computed column %s changed, it is dropped and added again", change.Name))
+			if change.Previous.Computed == "" {
+				statements = append(statements, fmt.Sprintf("-- WARNING: the values stored in %s are lost when it becomes computed", change.Name))
+			}
+			if change.Current.Computed == "" {
+				statements = append(statements, fmt.Sprintf("-- WARNING: %s is added again empty, reload it from the source", change.Name))
+				if !change.Current.Nullable {
+					statements = append(statements, fmt.Sprintf("-- WARNING: %s is NOT NULL without a default, this fails if the table has rows", change.Name))
+				}
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, quoteName(change.Name)))
+			if change.Current.Computed != "" {
+				statements = append(statements, sqlServerComputed(table, change.Current))
+			} else {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s %s %s;",
+					table,
+					quoteName(change.Name),
+					sqlServerType(change.Current.column()),
+					sqlServerNull(change.Current),
+				))
+			}
+			continue
+		}
+
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s %s;",
+			table,
+			quoteName(change.Name),
+			sqlServerType(change.Current.column()),
+			sqlServerNull(change.Current),
+		))
+	}
+
+	for _, column := range changes.RemovedColumns {
+		statements = append(statements,
+			fmt.Sprintf("-- WARNING: %s was removed from the source", column.Name),
+			fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;", table, quoteName(column.Name)),
+		)
+	}
+
+	return statements
+}
+
+// ALTER TABLE statements bringing a postgres table in line with the current columns.
+func postgresMigration(changes TableChanges) []string {
+
+	dialect := dialects["postgres"]
+	table := dialect.tableName(changes.Name)
+	statements := make([]string, 0)
+
+	for _, catalogColumn := range changes.AddedColumns {
+		dataType, ok := dialect.columnType(catalogColumn.column())
+		if !ok {
+			statements = append(statements, fmt.Sprintf("-- WARNING: %s of type %s has no postgres mapping", catalogColumn.Name, catalogColumn.DataType))
+			continue
+		}
+
+		var defaultValue string
+		if catalogColumn.Default != "" {
+			if value, ok := postgresDefault(catalogColumn); ok {
+				defaultValue = " DEFAULT " + value
+			} else {
+				statements = append(statements, fmt.Sprintf("-- WARNING: %s default not translated, %s has no postgres equivalent", catalogColumn.Name, catalogColumn.Default))
+			}
+		} else if !catalogColumn.Nullable {
+			statements = append(statements, fmt.Sprintf("-- WARNING: %s is NOT NULL without a default, this fails if the table has rows", catalogColumn.Name))
+		}
+
+		var null string
+		if !catalogColumn.Nullable {
+			null = " NOT NULL"
+		}
+
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s%s;",
+			table,
+			dialect.quote(catalogColumn.Name),
+			dataType,
+			null,
+			defaultValue,
+		))
+	}
+
+	for _, change := range changes.ChangedColumns {
+		if onlyPrimaryKeyChanged(change) {
+			statements = append(statements, fmt.Sprintf("-- WARNING: %s changed its primary key membership, review the key constraint", change.Name))
+			continue
+		}
+
+		previousType, _ := dialect.columnType(change.Previous.column())
+		dataType, ok := dialect.columnType(change.Current.column())
+		if !ok {
+			statements = append(statements, fmt.Sprintf("-- WARNING: %s of type %s has no postgres mapping", change.Name, change.Current.DataType))
+		} else if dataType != previousType {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;",
+				table,
+				dialect.quote(change.Name),
+				dataType,
+			))
+		}
+
+		if change.Previous.Nullable != change.Current.Nullable {
+			action := "SET NOT NULL"
+			if change.Current.Nullable {
+				action = "DROP NOT NULL"
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;",
+				table,
+				dialect.quote(change.Name),
+				action,
+			))
+		}
+	}
+
+	for _, column := range changes.RemovedColumns {
+		statements = append(statements,
+			fmt.Sprintf("-- WARNING: %s was removed from the source", column.Name),
+			fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;", table, dialect.quote(column.Name)),
+		)
+	}
+
+	return statements
+}
+
+// Add a SQL Server computed column from its expression.
+func sqlServerComputed(table string, column CatalogColumn) string {
+	var persisted string
+	if column.Persisted {
+		persisted = " PERSISTED"
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD %s AS %s%s;", table, quoteName(column.Name), column.Computed, persisted)
+}
+
+// SQL Server default literals, which sys.default_constraints wraps in brackets.
+var numberDefault = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+var stringDefault = regexp.MustCompile(`^N?'((?:[^']|'')*)'$`)
+
+// The SQL Server functions a default can call which postgres has under another name.
+var postgresDefaultFunctions = map[string]string{
+	"getdate()": "CURRENT_TIMESTAMP",
+	"sysdatetime()": "CURRENT_TIMESTAMP",
+	"current_timestamp": "CURRENT_TIMESTAMP",
+	"getutcdate()": "(now() AT TIME ZONE 'utc')",
+	"sysutcdatetime()": "(now() AT TIME ZONE 'utc')",
+	"newid()": "gen_random_uuid()",
+}
+
+// Translate the default of a column to postgres, false when it is anything but a literal or a known function.
+func postgresDefault(column CatalogColumn) (string, bool) {
+	expression := strings.TrimSpace(column.Default)
+	for strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+		expression = strings.TrimSpace(expression[1:len(expression) - 1])
+	}
+
+	switch {
+		case numberDefault.MatchString(expression):
+			// Bit columns become booleans.
+			if column.DataType == "bit" {
+				return strconv.FormatBool(expression != "0"), true
+			}
+			return expression, true
+		case stringDefault.MatchString(expression):
+			return strings.TrimPrefix(expression, "N"), true
+	}
+	value, ok := postgresDefaultFunctions[strings.ToLower(expression)]
+	return value, ok
+}
+
+// The SQL Server nullability of a catalog column.
+func sqlServerNull(column CatalogColumn) string {
+	if column.Nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// Whether the primary key flag is the only difference, which ALTER COLUMN cannot express.
+func onlyPrimaryKeyChanged(change ColumnChange) bool {
+	return len(change.Fields) == 1 && change.Fields[0] == "primaryKey"
+}
\ No newline at end of file
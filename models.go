@@ -15,19 +15,122 @@ type Config struct {
 	Mode string
 	Whitelist []string
 	Blacklist []string
+	Include []string
+	Exclude []string
+	Sources []string
 	Type2 []string
 	Timestamps []string
+	Watermarks string
+	Drift string
+	Hpsm HpsmConfig
+	Entities []EntityConfig
+	Profile ProfileConfig
+	Reconcile ReconcileConfig
+	Rules []RuleConfig
+	Tables map[string]TableConfig
+	Queries []QueryConfig
+}
+
+// Typedef for a named query extracted like a table
+type QueryConfig struct {
+	Name string
+	SQL string
+	Parameters map[string]interface{}
+	Watermark string
+}
+
+// Typedef for the settings of a single table
+type TableConfig struct {
+	Include []string
+	Exclude []string
+	Where string
+	Format string
+	Compression string
+	File string
+	Watermark string
+}
+
+// Typedef for the HP Service Manager options
+type HpsmConfig struct {
+	Dbdict string
+	LogicalHeaders bool
+}
+
+// Typedef for the profiling options
+type ProfileConfig struct {
+	Mode string
+	Sample int
+	Distinct string
+	Top int
+}
+
+// Typedef for the row count checks, as percentages
+type ReconcileConfig struct {
+	Tolerance *float64
+	Drop *float64
+	Spike *float64
+}
+
+// Typedef for a data quality rule
+type RuleConfig struct {
+	Table string
+	Column string
+	Columns []string
+	Check string
+	Values []string
+	Pattern string
+	Min string
+	Max string
+	Hours float64
+	Severity string
+}
+
+// Typedef for a logical entity, stored across several tables which share a key
+type EntityConfig struct {
+	Name string
+	Tables []string
+	Key []string
+	Aggregate bool
+}
+
+// Typedef for database level information
+type DatabaseInfo struct {
+	serverVersion string
+	edition string
+	compatibilityLevel int
+	collation string
 }
 
 // Typedef for tables
 type Table struct {
 	name string
+	schema string
+	description string
 	rowCount int
 	folder string
 	where string
+	kind string
+	base string
+	definition string
+	query string
+	parameters []interface{}
+	filter string
+	format string
+	compression string
+	file string
+	observers []RowObserver
 	timestamp string
+	watermarkStrategy string
+	watermarkReason string
 	type2 bool
+	drifted bool
+	header []string
 	columns []Column
+	primaryKey PrimaryKey
+	foreignKeys []ForeignKey
+	uniqueConstraints []UniqueConstraint
+	checkConstraints []CheckConstraint
+	indexes []Index
 }
 
 // Typedef for columns
@@ -41,4 +144,65 @@ type Column struct {
 	ordinalPosition sql.NullString
 	collationName sql.NullString
 	primaryKey sql.NullString
+	defaultName sql.NullString
+	defaultDefinition sql.NullString
+	identity sql.NullString
+	identitySeed sql.NullString
+	identityIncrement sql.NullString
+	computed sql.NullString
+	computedDefinition sql.NullString
+	persisted sql.NullString
+	description sql.NullString
+	logicalName sql.NullString
+	structure sql.NullString
+	array sql.NullString
+}
+
+// Typedef for primary keys
+type PrimaryKey struct {
+	name string
+	clustered bool
+	columns []IndexColumn
+}
+
+// Typedef for foreign keys
+type ForeignKey struct {
+	name string
+	columns []string
+	referencedTable string
+	referencedColumns []string
+	deleteAction string
+	updateAction string
+}
+
+// Typedef for unique constraints
+type UniqueConstraint struct {
+	name string
+	columns []string
+}
+
+// Typedef for check constraints
+type CheckConstraint struct {
+	name string
+	column string
+	definition string
+}
+
+// Typedef for indexes
+type Index struct {
+	name string
+	indexType string
+	clustered bool
+	unique bool
+	primaryKey bool
+	uniqueConstraint bool
+	keyColumns []IndexColumn
+	includedColumns []string
+	filterDefinition string
+}
+
+// Typedef for index key columns
+type IndexColumn struct {
+	name string
+	descending bool
 }
\ No newline at end of file
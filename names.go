@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Typedef for the case rules of the database collation
+type nameRules struct {
+	caseSensitive bool
+}
+
+// The rules config names are matched with, ignoring case until the database collation is known.
+var collationRules nameRules
+
+// Case sensitive and binary collations tell upper and lower case names apart.
+var caseSensitiveCollation = regexp.MustCompile(`(?i)_(CS|BIN2?)(_|$)`)
+
+func newNameRules(collation string) nameRules {
+	return nameRules{caseSensitive: caseSensitiveCollation.MatchString(collation)}
+}
+
+// Whether a config name refers to a catalog name.
+func sameName(configured string, actual string) bool {
+	if collationRules.caseSensitive {
+		return configured == actual
+	}
+	return strings.EqualFold(configured, actual)
+}
+
+// The key a name is stored under in a lookup map.
+func nameKey(name string) string {
+	if collationRules.caseSensitive {
+		return name
+	}
+	return strings.ToUpper(name)
+}
+
+// The catalog spelling of a config name.
+func resolveName(configured string, actual []string) (string, bool) {
+	for _, name := range actual {
+		if sameName(configured, name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Log the config names which do not match anything in the catalog.
+func reportUnmatched(setting string, configured []string, actual []string) {
+	for _, name := range configured {
+		if _, ok := resolveName(name, actual); !ok {
+			log.Println(fmt.Sprintf("The %s entry %s does not match anything", setting, name))
+		}
+	}
+}
+
+// The catalog spelling of a column name.
+func columnName(table Table, name string) (string, bool) {
+	for _, column := range table.columns {
+		if sameName(name, column.name.String) {
+			return column.name.String, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestNameRules(t *testing.T) {
+	for collation, caseSensitive := range map[string]bool{
+		"SQL_Latin1_General_CP1_CI_AS": false,
+		"Latin1_General_100_CI_AI_SC": false,
+		"Latin1_General_CS_AS": true,
+		"Latin1_General_BIN2": true,
+		"Japanese_XJIS_140_CS_AS_KS_WS": true,
+	} {
+		if newNameRules(collation).caseSensitive != caseSensitive {
+			t.Fatal("Unexpected case rules for", collation)
+		}
+	}
+
+	defer func() { collationRules = nameRules{} }()
+	table := Table{name: "INCIDENTSM1", columns: []Column{testColumn("SysModTime", "datetime", "8", "23", "3", "true")}}
+
+	collationRules = newNameRules("SQL_Latin1_General_CP1_CI_AS")
+	if name, ok := columnName(table, "SYSMODTIME"); !ok || name != "SysModTime" {
+		t.Fatal("Config names should resolve to the catalog spelling", name)
+	}
+	if name, ok := resolveName("incidentsm1", []string{"LOCM1", "INCIDENTSM1"}); !ok || name != "INCIDENTSM1" {
+		t.Fatal("Table names should ignore case", name)
+	}
+	if validateQueries([]QueryConfig{{Name: "incidentsm1", SQL: "SELECT 1"}}, []string{"INCIDENTSM1"}) == nil {
+		t.Fatal("A query should not take a table name in another case")
+	}
+
+	collationRules = newNameRules("Latin1_General_CS_AS")
+	if hasColumn(table, "SYSMODTIME") || !hasColumn(table, "SysModTime") || nameKey("a") == nameKey("A") {
+		t.Fatal("A case sensitive collation should tell the names apart")
+	}
+	if validateQueries([]QueryConfig{{Name: "orders", SQL: "SELECT 1"}}, []string{"ORDERS"}) != nil {
+		t.Fatal("A case sensitive collation should let a query differ from a table by case")
+	}
+	selected, err := selectTables([]TableName{{schema: "dbo", name: "Orders"}, {schema: "dbo", name: "ORDERS"}}, []string{"Ord*"}, nil)
+	if err != nil || len(selected) != 1 || selected[0].name != "Orders" {
+		t.Fatal("Patterns should follow the collation", selected, err)
+	}
+}
+
+func TestEntityKeySpelling(t *testing.T) {
+	tables := []Table{
+		{name: "ASSIGNMENTM1", columns: []Column{testColumn("Name", "nvarchar", "120", "0", "0", "false")}},
+		{name: "ASSIGNMENTM2", columns: []Column{testColumn("NAME", "nvarchar", "120", "0", "0", "false"), testColumn("DESCRIPTION", "ntext", "16", "0", "0", "true")}},
+	}
+
+	joined, err := buildEntity(EntityConfig{Name: "assignment", Tables: []string{"assignmentm1", "ASSIGNMENTM2"}, Key: []string{"name"}}, tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(joined.query, "LEFT JOIN [ASSIGNMENTM2] t1 ON t1.[NAME] = t0.[Name]") {
+		t.Fatal("The join should use the catalog spelling of the key", joined.query)
+	}
+}
+
+func TestDbdictNames(t *testing.T) {
+	defer func() { collationRules = nameRules{} }()
+	collationRules = newNameRules("SQL_Latin1_General_CP1_CI_AS")
+
+	fields := map[string]logicalField{
+		dbdictKey("PROBSUMMARYM1", "CATEGORY"): {name: "category"},
+		dbdictKey("sm.CM3RM1", "NUMBER"): {name: "number"},
+	}
+	tables := []Table{
+		{name: "sm.ProbSummaryM1", schema: "sm", columns: []Column{testColumn("Category", "nvarchar", "120", "0", "0", "true")}},
+		{name: "sm.CM3RM1", schema: "sm", columns: []Column{testColumn("NUMBER", "nvarchar", "120", "0", "0", "false")}},
+	}
+	applyDbdicts(tables, fields)
+	if tables[0].columns[0].logicalName.String != "category" {
+		t.Fatal("A table outside dbo should match its dbdict by name, ignoring case", tables[0].columns[0])
+	}
+	if tables[1].columns[0].logicalName.String != "number" {
+		t.Fatal("A qualified dbdict table should match", tables[1].columns[0])
+	}
+
+	collationRules = newNameRules("Latin1_General_CS_AS")
+	tables[0].columns[0].logicalName = sql.NullString{}
+	applyDbdicts(tables[:1], fields)
+	if tables[0].columns[0].logicalName.Valid {
+		t.Fatal("A case sensitive collation should tell the names apart", tables[0].columns[0])
+	}
+}
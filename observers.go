@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Typedef for anything which watches the rows of a table while getTableData writes them
+type RowObserver interface {
+	// Called with the scanned and formatted values of every row.
+	observe(values []interface{}, row []string)
+	// Called once the table is written, to add what was seen to its manifest entry.
+	finish(entry *ManifestEntry)
+}
+
+// Typedef for the run manifest, written out as manifest.json
+type Manifest struct {
+	Generated time.Time `json:"generated"`
+	Tables []ManifestEntry `json:"tables"`
+}
+
+// Typedef for an extracted table in the manifest
+type ManifestEntry struct {
+	Table string `json:"table"`
+	File string `json:"file"`
+	Since string `json:"since,omitempty"`
+	Rows int `json:"rows"`
+	Nulls map[string]int `json:"nulls"`
+	Watermark *ManifestWatermark `json:"watermark,omitempty"`
+	SHA256 string `json:"sha256"`
+	Profile string `json:"profile,omitempty"`
+	Quality string `json:"quality,omitempty"`
+}
+
+// Typedef for the range of the watermark column in an extract
+type ManifestWatermark struct {
+	Column string `json:"column"`
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// The manifest is filled in by the workers as they finish each table.
+var manifest Manifest
+var manifestLock sync.Mutex
+
+func addManifestEntry(entry ManifestEntry) {
+	manifestLock.Lock()
+	defer manifestLock.Unlock()
+	manifest.Tables = append(manifest.Tables, entry)
+}
+
+// Write out the manifest of everything extracted.
+func writeManifest(path string) {
+	manifestLock.Lock()
+	defer manifestLock.Unlock()
+
+	manifest.Generated = time.Now()
+	if manifest.Tables == nil {
+		manifest.Tables = make([]ManifestEntry, 0)
+	}
+	sort.Slice(manifest.Tables, func(i, j int) bool {
+		return manifest.Tables[i].Table < manifest.Tables[j].Table
+	})
+	writeJSON(path, manifest)
+}
+
+// The observers every extract gets, followed by any the table was given.
+func tableObservers(table Table) []RowObserver {
+	observers := []RowObserver{
+		&rowCounter{},
+		newNullCounter(table),
+		newWatermarkObserver(table),
+		&contentHash{hash: sha256.New()},
+	}
+	return append(observers, table.observers...)
+}
+
+// The manifest entry for a table, before the observers add to it.
+func newManifestEntry(table Table) ManifestEntry {
+	return ManifestEntry{
+		Table: table.name,
+		File: filepath.Base(table.folder) + string(filepath.Separator) + dataFile(table),
+		Since: table.where,
+	}
+}
+
+// Counts the rows written.
+type rowCounter struct {
+	rows int
+}
+
+func (counter *rowCounter) observe(values []interface{}, row []string) {
+	counter.rows++
+}
+
+func (counter *rowCounter) finish(entry *ManifestEntry) {
+	entry.Rows = counter.rows
+}
+
+// Counts the nulls in each column.
+type nullCounter struct {
+	names []string
+	nulls []int
+}
+
+func newNullCounter(table Table) *nullCounter {
+	counter := &nullCounter{nulls: make([]int, len(table.columns))}
+	for _, column := range table.columns {
+		counter.names = append(counter.names, column.name.String)
+	}
+	return counter
+}
+
+func (counter *nullCounter) observe(values []interface{}, row []string) {
+	for index, value := range values {
+		if value == nil {
+			counter.nulls[index]++
+		}
+	}
+}
+
+func (counter *nullCounter) finish(entry *ManifestEntry) {
+	entry.Nulls = make(map[string]int)
+	for index, name := range counter.names {
+		entry.Nulls[name] = counter.nulls[index]
+	}
+}
+
+// Tracks the earliest and latest watermark written.
+type watermarkObserver struct {
+	name string
+	index int
+	min time.Time
+	max time.Time
+	minVersion []byte
+	maxVersion []byte
+}
+
+func newWatermarkObserver(table Table) *watermarkObserver {
+	observer := &watermarkObserver{index: -1}
+	for index, column := range table.columns {
+
+		// Entities qualify the watermark with the alias of their first table.
+		if table.timestamp != "" && (sameName(table.timestamp, column.name.String) || table.timestamp == "t0." + quoteName(column.name.String)) {
+			observer.name = column.name.String
+			observer.index = index
+			break
+		}
+	}
+	return observer
+}
+
+func (observer *watermarkObserver) observe(values []interface{}, row []string) {
+	if observer.index < 0 {
+		return
+	}
+	switch value := values[observer.index].(type) {
+		case time.Time:
+			if observer.min.IsZero() || value.Before(observer.min) {
+				observer.min = value
+			}
+			if value.After(observer.max) {
+				observer.max = value
+			}
+		case []byte:
+			// Rowversions compare as big endian numbers.
+			if observer.minVersion == nil || bytes.Compare(value, observer.minVersion) < 0 {
+				observer.minVersion = value
+			}
+			if bytes.Compare(value, observer.maxVersion) > 0 {
+				observer.maxVersion = value
+			}
+	}
+}
+
+func (observer *watermarkObserver) finish(entry *ManifestEntry) {
+	if observer.index >= 0 && observer.minVersion != nil {
+		entry.Watermark = &ManifestWatermark{
+			Column: observer.name,
+			Min: fmt.Sprintf("0x%X", observer.minVersion),
+			Max: fmt.Sprintf("0x%X", observer.maxVersion),
+		}
+		return
+	}
+	if observer.index < 0 || observer.min.IsZero() {
+		return
+	}
+	entry.Watermark = &ManifestWatermark{
+		Column: observer.name,
+		Min: observer.min.Format(time.RFC3339),
+		Max: observer.max.Format(time.RFC3339),
+	}
+}
+
+// Hashes the written values, so unchanged extracts can be recognised.
+type contentHash struct {
+	hash hash.Hash
+}
+
+func (content *contentHash) observe(values []interface{}, row []string) {
+	for _, field := range row {
+		content.hash.Write([]byte(field))
+		content.hash.Write([]byte{0x1f})
+	}
+	content.hash.Write([]byte{0x1e})
+}
+
+func (content *contentHash) finish(entry *ManifestEntry) {
+	entry.SHA256 = hex.EncodeToString(content.hash.Sum(nil))
+}
\ No newline at end of file
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTableObservers(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		folder: filepath.Join("results", "2018_07_02", "tables"),
+		timestamp: "SYSMODTIME",
+		where: "2018-07-01T00:00:00Z",
+		columns: []Column{
+			testColumn("INCIDENT_ID", "nvarchar", "120", "0", "0", "false"),
+			testColumn("SYSMODTIME", "datetime", "8", "23", "3", "true"),
+		},
+	}
+	modified := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{"IM1", modified},
+		{"IM2", nil},
+		{"IM3", modified.Add(2 * time.Hour)},
+	}
+
+	run := func(rows [][]interface{}) ManifestEntry {
+		observers := tableObservers(table)
+		for _, values := range rows {
+			row := make([]string, 0)
+			for _, value := range values {
+				row = append(row, formatValue(value))
+			}
+			for _, observer := range observers {
+				observer.observe(values, row)
+			}
+		}
+		entry := newManifestEntry(table)
+		for _, observer := range observers {
+			observer.finish(&entry)
+		}
+		return entry
+	}
+
+	entry := run(rows)
+	if entry.File != filepath.Join("tables", "INCIDENTSM1.csv.gz") || entry.Since != table.where || entry.Rows != 3 {
+		t.Fatal("Unexpected manifest entry", entry)
+	}
+	if entry.Nulls["INCIDENT_ID"] != 0 || entry.Nulls["SYSMODTIME"] != 1 {
+		t.Fatal("Unexpected null counts", entry.Nulls)
+	}
+	if entry.Watermark == nil || entry.Watermark.Min != "2018-07-01T09:00:00Z" || entry.Watermark.Max != "2018-07-01T11:00:00Z" {
+		t.Fatal("Unexpected watermark range", entry.Watermark)
+	}
+
+	// The hash follows the content and its order.
+	if run(rows).SHA256 != entry.SHA256 || run(rows[1:]).SHA256 == entry.SHA256 {
+		t.Fatal("Content hash should only change with the rows")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// Typedef for the writers of the extract formats
+type rowWriter interface {
+	write(values []interface{}, row []string) error
+	flush() error
+}
+
+// The writer for a table's format, with the header row written when the format needs one.
+func newRowWriter(out io.Writer, table Table) (rowWriter, error) {
+	switch table.format {
+		case "jsonl":
+			names := table.header
+			if len(names) == 0 {
+				for _, column := range table.columns {
+					names = append(names, column.name.String)
+				}
+			}
+			return &jsonRowWriter{out: out, names: names, columns: table.columns}, nil
+		default:
+			writer := csv.NewWriter(out)
+			if table.format == "tsv" {
+				writer.Comma = '\t'
+			}
+
+			// Write the header row when the table has one.
+			if len(table.header) > 0 {
+				err := writer.Write(table.header)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return &csvRowWriter{writer: writer}, nil
+	}
+}
+
+// Writes CSV or TSV rows.
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func (output *csvRowWriter) write(values []interface{}, row []string) error {
+	return output.writer.Write(row)
+}
+
+func (output *csvRowWriter) flush() error {
+	output.writer.Flush()
+	return output.writer.Error()
+}
+
+// Writes a JSON object per line, with nulls kept as null and values typed like the JSON Schema of the table.
+type jsonRowWriter struct {
+	out io.Writer
+	names []string
+	columns []Column
+}
+
+func (output *jsonRowWriter) write(values []interface{}, row []string) error {
+	properties := orderedProperties{names: output.names}
+	for index, value := range values {
+		var column Column
+		if index < len(output.columns) {
+			column = output.columns[index]
+		}
+		properties.values = append(properties.values, jsonValue(column, value, row[index]))
+	}
+	line, err := json.Marshal(properties)
+	if err != nil {
+		return err
+	}
+	_, err = output.out.Write(append(line, '\n'))
+	return err
+}
+
+func (output *jsonRowWriter) flush() error {
+	return nil
+}
+
+// The JSON value of a column, numbers and booleans keep their type and everything else is the text written to CSV.
+func jsonValue(column Column, value interface{}, text string) interface{} {
+	switch v := value.(type) {
+		case nil:
+			return nil
+		case bool, int, int8, int16, int32, int64, float32, float64:
+			return v
+		case []byte:
+			// Decimals and money come from the driver as text, binary columns are base64 like the schema says.
+			property := jsonSchemaType(column)
+			if property["type"] == "number" {
+				return json.Number(v)
+			}
+			if _, ok := property["contentEncoding"]; ok {
+				return v
+			}
+	}
+	return text
+}
\ No newline at end of file
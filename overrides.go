@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Check the per table settings before starting.
+func validateTableConfigs(tables map[string]TableConfig) error {
+	for name, settings := range tables {
+		if settings.Format != "" && settings.Format != "csv" && settings.Format != "tsv" && settings.Format != "jsonl" {
+			return fmt.Errorf("Unknown format %s for %s, expected csv, tsv or jsonl", settings.Format, name)
+		}
+		if settings.Compression != "" && settings.Compression != "gzip" && settings.Compression != "none" {
+			return fmt.Errorf("Unknown compression %s for %s, expected gzip or none", settings.Compression, name)
+		}
+		if strings.ContainsAny(settings.File, `/\`) {
+			return fmt.Errorf("The file name for %s can not contain a path", name)
+		}
+	}
+	return nil
+}
+
+// The settings for a table, ignoring the case of its name.
+func tableSettings(tables map[string]TableConfig, name string) (TableConfig, bool) {
+	for key, settings := range tables {
+		if sameName(key, name) {
+			return settings, true
+		}
+	}
+	return TableConfig{}, false
+}
+
+// Apply the per table settings, leaving out the excluded columns and anything which depends on them.
+func applyTableConfig(table Table, settings TableConfig) Table {
+
+	include := make(map[string]bool)
+	for _, name := range settings.Include {
+		include[nameKey(name)] = true
+	}
+	exclude := make(map[string]bool)
+	for _, name := range settings.Exclude {
+		exclude[nameKey(name)] = true
+	}
+
+	kept := make(map[string]bool)
+	columns := make([]Column, 0)
+	for _, column := range table.columns {
+		name := nameKey(column.name.String)
+		if (len(include) > 0 && !include[name]) || exclude[name] {
+			continue
+		}
+		kept[name] = true
+		columns = append(columns, column)
+	}
+	for _, name := range append(settings.Include, settings.Exclude...) {
+		if !hasColumn(table, name) {
+			log.Println(fmt.Sprintf("The settings for %s name %s, which is not one of its columns", table.name, name))
+		}
+	}
+
+	if len(columns) < len(table.columns) {
+		table.columns = columns
+		keep := func(names ...string) bool {
+			for _, name := range names {
+				if !kept[nameKey(name)] {
+					return false
+				}
+			}
+			return true
+		}
+		indexColumns := func(columns []IndexColumn) []string {
+			names := make([]string, 0)
+			for _, column := range columns {
+				names = append(names, column.name)
+			}
+			return names
+		}
+
+		if !keep(indexColumns(table.primaryKey.columns)...) {
+			table.primaryKey = PrimaryKey{}
+		}
+
+		uniqueConstraints := make([]UniqueConstraint, 0)
+		for _, unique := range table.uniqueConstraints {
+			if keep(unique.columns...) {
+				uniqueConstraints = append(uniqueConstraints, unique)
+			}
+		}
+		table.uniqueConstraints = uniqueConstraints
+
+		checkConstraints := make([]CheckConstraint, 0)
+		for _, check := range table.checkConstraints {
+			if check.column == "" || keep(check.column) {
+				checkConstraints = append(checkConstraints, check)
+			}
+		}
+		table.checkConstraints = checkConstraints
+
+		foreignKeys := make([]ForeignKey, 0)
+		for _, foreignKey := range table.foreignKeys {
+			if keep(foreignKey.columns...) {
+				foreignKeys = append(foreignKeys, foreignKey)
+			}
+		}
+		table.foreignKeys = foreignKeys
+
+		// Indexes go with their key columns, and lose any included columns which are left out.
+		indexes := make([]Index, 0)
+		for _, index := range table.indexes {
+			if !keep(indexColumns(index.keyColumns)...) {
+				continue
+			}
+			included := make([]string, 0)
+			for _, name := range index.includedColumns {
+				if keep(name) {
+					included = append(included, name)
+				}
+			}
+			index.includedColumns = included
+			indexes = append(indexes, index)
+		}
+		table.indexes = indexes
+	}
+
+	table.filter = settings.Where
+	applyOutputConfig(&table, settings)
+	return table
+}
+
+// Apply the output settings of a table or entity.
+func applyOutputConfig(table *Table, settings TableConfig) {
+	table.format = settings.Format
+	table.compression = settings.Compression
+	table.file = settings.File
+}
+
+// The file a table is extracted to, relative to its folder.
+func dataFile(table Table) string {
+	name := table.file
+	if name == "" {
+		name = table.name
+	}
+
+	format := table.format
+	if format == "" {
+		format = "csv"
+	}
+	name += "." + format
+
+	if table.compression != "none" {
+		name += ".gz"
+	}
+	return name
+}
\ No newline at end of file
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestApplyTableConfig(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		columns: []Column{
+			testColumn("INCIDENT_ID", "nvarchar", "120", "0", "0", "false"),
+			testColumn("LOCATION", "nvarchar", "120", "0", "0", "true"),
+			testColumn("ACTION", "ntext", "16", "0", "0", "true"),
+		},
+		primaryKey: PrimaryKey{name: "INCIDENTSM1_PK", columns: []IndexColumn{{name: "INCIDENT_ID"}}},
+		foreignKeys: []ForeignKey{{name: "INCIDENTSM1_LOC", columns: []string{"LOCATION"}, referencedTable: "LOCM1", referencedColumns: []string{"LOCATION"}}},
+		indexes: []Index{
+			{name: "INCIDENTSM1_PK", primaryKey: true, keyColumns: []IndexColumn{{name: "INCIDENT_ID"}}, includedColumns: []string{}},
+			{name: "INCIDENTSM1_LOCATION", keyColumns: []IndexColumn{{name: "LOCATION"}}, includedColumns: []string{"INCIDENT_ID"}},
+			{name: "INCIDENTSM1_COVER", keyColumns: []IndexColumn{{name: "INCIDENT_ID"}}, includedColumns: []string{"LOCATION", "ACTION"}},
+		},
+	}
+
+	result := applyTableConfig(table, TableConfig{Exclude: []string{"location"}, Where: "OPEN = 'true'", Format: "jsonl", Compression: "none", File: "incidents"})
+	if len(result.columns) != 2 || result.columns[1].name.String != "ACTION" {
+		t.Fatal("Excluded column was kept", result.columns)
+	}
+	if len(result.foreignKeys) != 0 || len(result.indexes) != 2 || len(result.indexes[1].includedColumns) != 1 || result.primaryKey.name != "INCIDENTSM1_PK" {
+		t.Fatal("Constraints and indexes on the excluded column should go", result.foreignKeys, result.indexes)
+	}
+	if result.filter != "OPEN = 'true'" || dataFile(result) != "incidents.jsonl" || dataFile(table) != "INCIDENTSM1.csv.gz" {
+		t.Fatal("Unexpected filter or file name", result.filter, dataFile(result))
+	}
+
+	result = applyTableConfig(table, TableConfig{Include: []string{"LOCATION"}})
+	if len(result.columns) != 1 || result.primaryKey.name != "" || len(result.indexes) != 1 {
+		t.Fatal("Only the included column should be kept", result.columns, result.indexes)
+	}
+
+	if validateTableConfigs(map[string]TableConfig{"INCIDENTSM1": {Format: "xml"}}) == nil {
+		t.Fatal("Unknown format should be refused")
+	}
+}
+
+func TestRowWriters(t *testing.T) {
+	table := Table{
+		name: "LOCM1",
+		format: "jsonl",
+		columns: []Column{
+			testColumn("LOCATION", "nvarchar", "120", "0", "0", "false"),
+			testColumn("CITY", "nvarchar", "120", "0", "0", "true"),
+		},
+	}
+
+	var out bytes.Buffer
+	writer, _ := newRowWriter(&out, table)
+	writer.write([]interface{}{"CBR", nil}, []string{"CBR", ""})
+	writer.flush()
+	if out.String() != "{\"LOCATION\":\"CBR\",\"CITY\":null}\n" {
+		t.Fatal("Unexpected JSON lines output", out.String())
+	}
+
+	// Values keep the types the JSON Schema gives them.
+	out.Reset()
+	typed := Table{
+		name: "PROBSUMMARYM1",
+		format: "jsonl",
+		columns: []Column{
+			testColumn("PRIORITY", "int", "4", "10", "0", "false"),
+			testColumn("OPEN", "bit", "1", "1", "0", "false"),
+			testColumn("COST", "decimal", "9", "12", "2", "true"),
+			testColumn("ATTACHMENT", "varbinary", "-1", "0", "0", "true"),
+			testColumn("CLOSED", "datetime", "8", "23", "3", "true"),
+		},
+	}
+	writer, _ = newRowWriter(&out, typed)
+	values := []interface{}{int64(42), true, []byte("12.50"), []byte{1, 2, 3}, nil}
+	row := make([]string, 0)
+	for _, value := range values {
+		row = append(row, formatValue(value))
+	}
+	writer.write(values, row)
+	writer.flush()
+	if out.String() != "{\"PRIORITY\":42,\"OPEN\":true,\"COST\":12.50,\"ATTACHMENT\":\"AQID\",\"CLOSED\":null}\n" {
+		t.Fatal("Unexpected typed JSON lines output", out.String())
+	}
+	if row[1] != "true" {
+		t.Fatal("Bit columns should be written as true or false", row[1])
+	}
+
+	out.Reset()
+	table.format = "tsv"
+	table.header = []string{"location", "city"}
+	writer, _ = newRowWriter(&out, table)
+	writer.write([]interface{}{"CBR", "Canberra"}, []string{"CBR", "Canberra"})
+	writer.flush()
+	if out.String() != "location\tcity\nCBR\tCanberra\n" {
+		t.Fatal("Unexpected TSV output", out.String())
+	}
+}
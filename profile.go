@@ -0,0 +1,341 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Typedef for the profile of a table, written out as profile/<table>.json
+type Profile struct {
+	Table string `json:"table"`
+	Mode string `json:"mode"`
+	Generated time.Time `json:"generated"`
+	RowCount int `json:"rowCount"`
+	Rows int `json:"rows"`
+	Since string `json:"since,omitempty"`
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// Typedef for the profile of a column, the counts are over the profiled rows
+type ColumnProfile struct {
+	Name string `json:"name"`
+	DataType string `json:"dataType"`
+	Nulls int `json:"nulls"`
+	Distinct int `json:"distinct"`
+	Estimated bool `json:"estimated"`
+	Min string `json:"min"`
+	Max string `json:"max"`
+	AverageLength *float64 `json:"averageLength,omitempty"`
+	Top []ValueCount `json:"top"`
+}
+
+// Typedef for a value and the number of rows holding it
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int `json:"count"`
+}
+
+// Types compared as numbers for the min and max.
+var numericTypes = map[string]bool{
+	"tinyint": true, "smallint": true, "int": true, "bigint": true,
+	"decimal": true, "numeric": true, "money": true, "smallmoney": true,
+	"float": true, "real": true,
+}
+
+// Types which get an average length.
+var textTypes = map[string]bool{
+	"char": true, "varchar": true, "nchar": true, "nvarchar": true, "text": true, "ntext": true,
+}
+
+// Typedef for the running profile of a table
+type tableProfiler struct {
+	path string
+	profile Profile
+	columns []*columnProfiler
+}
+
+// Typedef for the running profile of a column
+type columnProfiler struct {
+	column Column
+	top int
+	nulls int
+	length int
+	lengths int
+	min interface{}
+	max interface{}
+	counts map[string]int
+	sketch *hyperLogLog
+	frequent *spaceSaving
+}
+
+func newTableProfiler(table Table, config ProfileConfig, mode string, path string) *tableProfiler {
+	profiler := &tableProfiler{
+		path: path,
+		profile: Profile{
+			Table: table.name,
+			Mode: mode,
+			RowCount: table.rowCount,
+			Since: table.where,
+		},
+	}
+
+	for _, column := range table.columns {
+		columnProfiler := &columnProfiler{column: column, top: config.Top}
+
+		// Exact counts keep every value, the estimates keep a fixed amount of memory.
+		if config.Distinct == "hll" {
+			columnProfiler.sketch = newHyperLogLog()
+			columnProfiler.frequent = newSpaceSaving(config.Top * 10)
+		} else {
+			columnProfiler.counts = make(map[string]int)
+		}
+		profiler.columns = append(profiler.columns, columnProfiler)
+	}
+
+	return profiler
+}
+
+// Add a row of scanned values to the profile.
+func (profiler *tableProfiler) observe(values []interface{}, row []string) {
+	profiler.profile.Rows++
+	for index, value := range values {
+		profiler.columns[index].observe(value)
+	}
+}
+
+func (profiler *columnProfiler) observe(value interface{}) {
+	if value == nil {
+		profiler.nulls++
+		return
+	}
+
+	text := formatValue(value)
+	if profiler.counts != nil {
+		profiler.counts[text]++
+	} else {
+		profiler.sketch.add(text)
+		profiler.frequent.add(text)
+	}
+
+	if textTypes[profiler.column.dataType.String] {
+		profiler.length += utf8.RuneCountInString(text)
+		profiler.lengths++
+	}
+
+	if profiler.min == nil || profiler.less(value, profiler.min) {
+		profiler.min = value
+	}
+	if profiler.max == nil || profiler.less(profiler.max, value) {
+		profiler.max = value
+	}
+}
+
+// Order values by time, number or text, depending on the column.
+func (profiler *columnProfiler) less(a interface{}, b interface{}) bool {
+	if aTime, ok := a.(time.Time); ok {
+		if bTime, ok := b.(time.Time); ok {
+			return aTime.Before(bTime)
+		}
+	}
+	aText, bText := formatValue(a), formatValue(b)
+	if numericTypes[profiler.column.dataType.String] {
+		aNumber, aErr := strconv.ParseFloat(aText, 64)
+		bNumber, bErr := strconv.ParseFloat(bText, 64)
+		if aErr == nil && bErr == nil {
+			return aNumber < bNumber
+		}
+	}
+	return aText < bText
+}
+
+func (profiler *columnProfiler) result() ColumnProfile {
+	result := ColumnProfile{
+		Name: profiler.column.name.String,
+		DataType: profiler.column.dataType.String,
+		Nulls: profiler.nulls,
+		Top: make([]ValueCount, 0),
+	}
+	if profiler.min != nil {
+		result.Min = formatValue(profiler.min)
+		result.Max = formatValue(profiler.max)
+	}
+	if profiler.lengths > 0 {
+		average := float64(profiler.length) / float64(profiler.lengths)
+		result.AverageLength = &average
+	}
+
+	counts := profiler.counts
+	if counts != nil {
+		result.Distinct = len(counts)
+	} else {
+		result.Distinct = profiler.sketch.estimate()
+		result.Estimated = true
+		counts = profiler.frequent.counts
+	}
+
+	for value, count := range counts {
+		result.Top = append(result.Top, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(result.Top, func(i, j int) bool {
+		if result.Top[i].Count != result.Top[j].Count {
+			return result.Top[i].Count > result.Top[j].Count
+		}
+		return result.Top[i].Value < result.Top[j].Value
+	})
+	if len(result.Top) > profiler.top {
+		result.Top = result.Top[:profiler.top]
+	}
+
+	return result
+}
+
+// Write the profile out to disk once the extract is done.
+func (profiler *tableProfiler) finish(entry *ManifestEntry) {
+	profiler.write()
+	entry.Profile = filepath.Base(filepath.Dir(profiler.path)) + string(filepath.Separator) + filepath.Base(profiler.path)
+}
+
+// Write the profile out to disk.
+func (profiler *tableProfiler) write() {
+	profiler.profile.Generated = time.Now()
+	profiler.profile.Columns = make([]ColumnProfile, 0)
+	for _, column := range profiler.columns {
+		profiler.profile.Columns = append(profiler.profile.Columns, column.result())
+	}
+	writeJSON(profiler.path, profiler.profile)
+}
+
+// The query reading a sample of a table's rows, the sample size is the @sample parameter.
+func sampleQuery(table Table, sample int) string {
+
+	queryString := fmt.Sprintf("SELECT TOP (@sample) %s FROM %s", selectList(table.columns, ""), table.sqlName())
+
+	// Read a spread of pages on larger tables, rather than the first rows of the clustered index.
+	// Only base tables can be sampled, views, synonyms and functions just take the first rows.
+	if table.kind == "" && table.rowCount > sample * 2 {
+		percent := int(math.Ceil(float64(sample) * 200 / float64(table.rowCount)))
+		queryString += fmt.Sprintf(" TABLESAMPLE (%d PERCENT)", percent)
+	}
+	if table.filter != "" {
+		queryString += fmt.Sprintf(" WHERE (%s)", table.filter)
+	}
+	return queryString
+}
+
+// Profile a sample of a table's rows with a separate query.
+func profileSample(table Table, profiler *tableProfiler, sample int, dbConnection* sql.DB) {
+
+	queryString := sampleQuery(table, sample)
+	query, err := dbConnection.Query(queryString, sql.Named("sample", sample))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	for query.Next() {
+		values := make([]interface{}, len(table.columns))
+		pointers := make([]interface{}, len(table.columns))
+		for index := range values {
+			pointers[index] = &values[index]
+		}
+		err := query.Scan(pointers...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profiler.observe(values, nil)
+	}
+
+	profiler.write()
+}
+
+// HyperLogLog distinct count estimate, with 2^14 registers for about 1% error.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+const hyperLogLogPrecision = 14
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1 << hyperLogLogPrecision)}
+}
+
+func (sketch *hyperLogLog) add(value string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+
+	// Mix the FNV hash so the high bits are spread well enough to pick the register.
+	x := hash.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	register := x >> (64 - hyperLogLogPrecision)
+	rank := uint8(1)
+	for remaining := x << hyperLogLogPrecision; remaining & (1 << 63) == 0 && rank <= 64 - hyperLogLogPrecision; remaining <<= 1 {
+		rank++
+	}
+	if rank > sketch.registers[register] {
+		sketch.registers[register] = rank
+	}
+}
+
+func (sketch *hyperLogLog) estimate() int {
+	m := float64(len(sketch.registers))
+	sum := 0.0
+	zeros := 0
+	for _, register := range sketch.registers {
+		sum += math.Pow(2, -float64(register))
+		if register == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079 / m) * m * m / sum
+
+	// Small counts are better estimated from the empty registers.
+	if estimate <= 2.5 * m && zeros > 0 {
+		estimate = m * math.Log(m / float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
+
+// Space-Saving top values, keeping a fixed number of counters which over count by at most the smallest counter.
+type spaceSaving struct {
+	capacity int
+	counts map[string]int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	if capacity < 100 {
+		capacity = 100
+	}
+	return &spaceSaving{capacity: capacity, counts: make(map[string]int)}
+}
+
+func (frequent *spaceSaving) add(value string) {
+	if _, ok := frequent.counts[value]; ok || len(frequent.counts) < frequent.capacity {
+		frequent.counts[value]++
+		return
+	}
+
+	// Replace the smallest counter, taking over its count.
+	smallest := ""
+	minimum := -1
+	for key, count := range frequent.counts {
+		if minimum == -1 || count < minimum {
+			smallest = key
+			minimum = count
+		}
+	}
+	delete(frequent.counts, smallest)
+	frequent.counts[value] = minimum + 1
+}
\ No newline at end of file
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestColumnProfile(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		rowCount: 6,
+		columns: []Column{
+			testColumn("STATUS", "nvarchar", "60", "0", "0", "true"),
+			testColumn("PRIORITY", "decimal", "9", "18", "0", "true"),
+			testColumn("OPEN_TIME", "datetime", "8", "23", "3", "true"),
+		},
+	}
+	opened := time.Date(2018, 7, 1, 9, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{"Open", []byte("3"), opened},
+		{"Open", []byte("10"), opened.Add(time.Hour)},
+		{"Closed", []byte("2"), opened.Add(-time.Hour)},
+		{"Open", nil, nil},
+		{nil, []byte("3"), opened},
+	}
+
+	profiler := newTableProfiler(table, ProfileConfig{Top: 1}, "stream", "")
+	for _, row := range rows {
+		profiler.observe(row, nil)
+	}
+
+	status := profiler.columns[0].result()
+	if status.Nulls != 1 || status.Distinct != 2 || status.Min != "Closed" || status.Max != "Open" || *status.AverageLength != 4.5 {
+		t.Fatal("Unexpected text column profile", status)
+	}
+	if len(status.Top) != 1 || status.Top[0].Value != "Open" || status.Top[0].Count != 3 {
+		t.Fatal("Unexpected top values", status.Top)
+	}
+
+	// Decimals arrive as text, but are ordered as numbers.
+	priority := profiler.columns[1].result()
+	if priority.Min != "2" || priority.Max != "10" || priority.AverageLength != nil {
+		t.Fatal("Unexpected numeric column profile", priority)
+	}
+
+	openTime := profiler.columns[2].result()
+	if openTime.Min != "2018-07-01T08:00:00Z" || openTime.Max != "2018-07-01T10:00:00Z" {
+		t.Fatal("Unexpected date column profile", openTime)
+	}
+}
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	for _, distinct := range []int{100, 50000} {
+		sketch := newHyperLogLog()
+		for i := 0; i < distinct; i++ {
+			sketch.add(fmt.Sprintf("IM%08d", i))
+			sketch.add(fmt.Sprintf("IM%08d", i))
+		}
+		estimate := sketch.estimate()
+		if math.Abs(float64(estimate - distinct)) > float64(distinct) * 0.05 {
+			t.Fatal("Estimate is more than 5% out", distinct, estimate)
+		}
+	}
+}
+
+func TestSampleQuery(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		schema: "dbo",
+		rowCount: 100000,
+		columns: []Column{testColumn("NUMBER", "varchar", "60", "0", "0", "false")},
+	}
+	if query := sampleQuery(table, 10000); query != "SELECT TOP (@sample) [NUMBER] FROM [dbo].[INCIDENTSM1] TABLESAMPLE (20 PERCENT)" {
+		t.Fatal("Large tables should be sampled", query)
+	}
+
+	// Views can not be sampled, so they take the first rows.
+	table.name = "OPENINCIDENTS"
+	table.kind = "view"
+	table.filter = "OPEN = 't'"
+	if query := sampleQuery(table, 10000); query != "SELECT TOP (@sample) [NUMBER] FROM [dbo].[OPENINCIDENTS] WHERE (OPEN = 't')" {
+		t.Fatal("Views should not use TABLESAMPLE", query)
+	}
+}
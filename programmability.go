@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Typedef for a procedure, function or trigger
+type Module struct {
+	schema string
+	name string
+	kind string
+	definition sql.NullString
+}
+
+// Typedef for the programmability changes between two runs, written out as changes.json
+type ModuleChanges struct {
+	Previous string `json:"previous"`
+	Objects int `json:"objects"`
+	Added []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// The kind of each sys.objects type with a module definition.
+var moduleKinds = map[string]string{
+	"P": "procedure",
+	"FN": "function",
+	"IF": "function",
+	"TF": "function",
+	"TR": "trigger",
+}
+
+func getModules(dbConnection* sql.DB) ([]Module) {
+
+	// Database triggers are not schema scoped, so they are not in sys.objects.
+	queryString := `
+		SELECT
+		    s.name 'Schema Name',
+		    o.name 'Object Name',
+		    RTRIM(o.type) 'Object Type',
+		    m.definition 'Definition'
+		FROM
+		    sys.sql_modules m
+		INNER JOIN
+		    sys.objects o ON o.object_id = m.object_id
+		INNER JOIN
+		    sys.schemas s ON s.schema_id = o.schema_id
+		WHERE
+		    o.is_ms_shipped = 0 AND o.type IN ('P', 'FN', 'IF', 'TF', 'TR')
+		UNION ALL
+		SELECT
+		    '',
+		    t.name,
+		    'TR',
+		    m.definition
+		FROM
+		    sys.triggers t
+		INNER JOIN
+		    sys.sql_modules m ON m.object_id = t.object_id
+		WHERE
+		    t.parent_class = 0 AND t.is_ms_shipped = 0
+		ORDER BY
+		    1, 2
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	modules := make([]Module, 0)
+
+	// Go through the results and create an array of results.
+	for query.Next() {
+		var module Module
+		var objectType string
+		query.Scan(&module.schema, &module.name, &objectType, &module.definition)
+		module.kind = moduleKinds[objectType]
+		modules = append(modules, module)
+	}
+
+	return modules
+}
+
+// The script of a module, encrypted modules have no definition to show.
+func (module Module) script() string {
+	if !module.definition.Valid {
+		return fmt.Sprintf("-- The %s %s is encrypted\n", module.kind, TableName{schema: module.schema, name: module.name}.identity())
+	}
+	return strings.TrimSpace(module.definition.String) + "\n"
+}
+
+// Write a file per module, returning the scripts by file name.
+func writeModules(modules []Module, folder string) map[string]string {
+	scripts := make(map[string]string)
+	for _, module := range modules {
+		name := TableName{schema: module.schema, name: module.name}.identity() + ".sql"
+		scripts[name] = module.script()
+
+		err := ioutil.WriteFile(folder + string(filepath.Separator) + name, []byte(scripts[name]), 0666)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return scripts
+}
+
+// Compare the scripts with those written by the previous run.
+func compareModules(previousRun string, previousFolder string, scripts map[string]string) ModuleChanges {
+
+	changes := ModuleChanges{
+		Previous: previousRun,
+		Objects: len(scripts),
+		Added: make([]string, 0),
+		Removed: make([]string, 0),
+		Changed: make([]string, 0),
+	}
+
+	// Without a previous run there is nothing to compare with.
+	if previousFolder == "" {
+		return changes
+	}
+
+	previous := make(map[string]string)
+	files, err := ioutil.ReadDir(previousFolder)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".sql" {
+			continue
+		}
+		content, err := ioutil.ReadFile(previousFolder + string(filepath.Separator) + file.Name())
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		previous[file.Name()] = string(content)
+	}
+
+	for name, script := range scripts {
+		previousScript, ok := previous[name]
+		if !ok {
+			changes.Added = append(changes.Added, strings.TrimSuffix(name, ".sql"))
+		} else if strings.TrimSpace(previousScript) != strings.TrimSpace(script) {
+			changes.Changed = append(changes.Changed, strings.TrimSuffix(name, ".sql"))
+		}
+	}
+	for name := range previous {
+		if _, ok := scripts[name]; !ok {
+			changes.Removed = append(changes.Removed, strings.TrimSuffix(name, ".sql"))
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+	return changes
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareModules(t *testing.T) {
+	previous, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(previous)
+	current, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(current)
+
+	definition := func(text string) sql.NullString {
+		return sql.NullString{String: text, Valid: true}
+	}
+	writeModules([]Module{
+		{schema: "dbo", name: "CloseIncident", kind: "procedure", definition: definition("CREATE PROCEDURE CloseIncident AS SELECT 1")},
+		{schema: "dbo", name: "OpenIncidents", kind: "function", definition: definition("CREATE FUNCTION OpenIncidents() RETURNS TABLE AS RETURN SELECT 1 AS A")},
+		{schema: "audit", name: "LogChange", kind: "trigger", definition: definition("CREATE TRIGGER audit.LogChange ON dbo.INCIDENTSM1 AFTER UPDATE AS SELECT 1")},
+	}, previous)
+
+	scripts := writeModules([]Module{
+		{schema: "dbo", name: "CloseIncident", kind: "procedure", definition: definition("CREATE PROCEDURE CloseIncident AS SELECT 1\r\n")},
+		{schema: "dbo", name: "OpenIncidents", kind: "function", definition: definition("CREATE FUNCTION OpenIncidents() RETURNS TABLE AS RETURN SELECT 2 AS A")},
+		{schema: "dbo", name: "Secret", kind: "procedure"},
+	}, current)
+
+	script, err := ioutil.ReadFile(filepath.Join(current, "Secret.sql"))
+	if err != nil || string(script) != "-- The procedure Secret is encrypted\n" {
+		t.Fatal("Encrypted modules should say so", string(script), err)
+	}
+	if _, err := os.Stat(filepath.Join(previous, "audit.LogChange.sql")); err != nil {
+		t.Fatal("Modules outside dbo should be named with their schema", err)
+	}
+
+	changes := compareModules("2018_07_01", previous, scripts)
+	if changes.Objects != 3 || len(changes.Added) != 1 || changes.Added[0] != "Secret" {
+		t.Fatal("Unexpected added modules", changes)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "audit.LogChange" {
+		t.Fatal("Unexpected removed modules", changes.Removed)
+	}
+	if len(changes.Changed) != 1 || changes.Changed[0] != "OpenIncidents" {
+		t.Fatal("Unexpected changed modules", changes.Changed)
+	}
+
+	changes = compareModules("", "", scripts)
+	if len(changes.Added) != 0 || len(changes.Changed) != 0 {
+		t.Fatal("Nothing should change without a previous run", changes)
+	}
+}
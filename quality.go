@@ -0,0 +1,332 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failing values kept as examples for each rule.
+const qualityExamples = 5
+
+// Typedef for the quality report of a table, written out as quality/<table>.json
+type QualityReport struct {
+	Table string `json:"table"`
+	Generated time.Time `json:"generated"`
+	Rows int `json:"rows"`
+	Results []RuleResult `json:"results"`
+}
+
+// Typedef for the outcome of a rule
+type RuleResult struct {
+	Check string `json:"check"`
+	Columns []string `json:"columns"`
+	Severity string `json:"severity"`
+	Passed bool `json:"passed"`
+	Failures int `json:"failures"`
+	Examples []string `json:"examples"`
+	Message string `json:"message"`
+}
+
+// The reports are collected by the workers as they finish each table.
+var qualityReports []QualityReport
+var qualityLock sync.Mutex
+
+// Check the rules in the config before starting.
+func validateRules(rules []RuleConfig) error {
+	for _, rule := range rules {
+		if rule.Table == "" {
+			return errors.New("Every rule needs a table")
+		}
+		if rule.Severity != "" && rule.Severity != "error" && rule.Severity != "warn" {
+			return fmt.Errorf("Unknown severity %s on the %s rule for %s, expected error or warn", rule.Severity, rule.Check, rule.Table)
+		}
+		switch rule.Check {
+			case "notnull", "allowed", "regex", "range", "unique":
+				if len(rule.columns()) == 0 {
+					return fmt.Errorf("The %s rule for %s needs a column", rule.Check, rule.Table)
+				}
+				if rule.Check == "regex" {
+					_, err := regexp.Compile(rule.Pattern)
+					if err != nil {
+						return fmt.Errorf("The regex rule for %s.%s: %v", rule.Table, rule.Column, err)
+					}
+				}
+			case "freshness":
+				if rule.Hours <= 0 {
+					return fmt.Errorf("The freshness rule for %s needs a number of hours", rule.Table)
+				}
+			default:
+				return fmt.Errorf("Unknown check %s for %s, expected notnull, unique, allowed, regex, range or freshness", rule.Check, rule.Table)
+		}
+	}
+	return nil
+}
+
+// The columns a rule applies to.
+func (rule RuleConfig) columns() []string {
+	if len(rule.Columns) > 0 {
+		return rule.Columns
+	}
+	if rule.Column != "" {
+		return []string{rule.Column}
+	}
+	return nil
+}
+
+// The rules which apply to a table.
+func tableRules(rules []RuleConfig, table string) []RuleConfig {
+	result := make([]RuleConfig, 0)
+	for _, rule := range rules {
+		if sameName(rule.Table, table) {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// Typedef for the running quality checks of a table
+type qualityChecker struct {
+	path string
+	report QualityReport
+	checks []*ruleCheck
+}
+
+// Typedef for a rule being checked against the rows
+type ruleCheck struct {
+	rule RuleConfig
+	result RuleResult
+	indexes []int
+	allowed map[string]bool
+	pattern *regexp.Regexp
+	seen map[string]bool
+}
+
+func newQualityChecker(table Table, rules []RuleConfig, path string) *qualityChecker {
+	checker := &qualityChecker{
+		path: path,
+		report: QualityReport{Table: table.name, Results: make([]RuleResult, 0)},
+	}
+
+	for _, rule := range rules {
+		check := &ruleCheck{
+			rule: rule,
+			result: RuleResult{
+				Check: rule.Check,
+				Columns: rule.columns(),
+				Severity: rule.Severity,
+				Passed: true,
+				Examples: make([]string, 0),
+			},
+		}
+		if check.result.Severity == "" {
+			check.result.Severity = "error"
+		}
+		if check.result.Columns == nil {
+			check.result.Columns = []string{table.timestamp}
+		}
+
+		// Find the columns the rule reads.
+		for _, name := range rule.columns() {
+			index := -1
+			for columnIndex, column := range table.columns {
+				if sameName(name, column.name.String) {
+					index = columnIndex
+				}
+			}
+			if index < 0 {
+				check.result.Passed = false
+				check.result.Message = fmt.Sprintf("%s is not a column of %s", name, table.name)
+			}
+			check.indexes = append(check.indexes, index)
+		}
+
+		switch rule.Check {
+			case "allowed":
+				check.allowed = make(map[string]bool)
+				for _, value := range rule.Values {
+					check.allowed[value] = true
+				}
+			case "regex":
+				check.pattern = regexp.MustCompile(rule.Pattern)
+			case "unique":
+				check.seen = make(map[string]bool)
+		}
+
+		checker.checks = append(checker.checks, check)
+	}
+
+	return checker
+}
+
+// Check the freshness rules against the newest watermark in the table.
+func (checker *qualityChecker) checkFreshness(table Table, dbConnection* sql.DB) {
+	for _, check := range checker.checks {
+		if check.rule.Check != "freshness" {
+			continue
+		}
+		column := check.rule.Column
+		if column == "" {
+			column = table.timestamp
+		}
+		if column == "" {
+			check.fail("", fmt.Sprintf("%s has no watermark column to check", table.name))
+			continue
+		}
+		if check.rule.Column == "" && rowversionWatermark(table) {
+			check.fail("", fmt.Sprintf("The %s watermark is a rowversion, name a datetime column to check", table.name))
+			continue
+		}
+		check.result.Columns = []string{column}
+
+		latest := getMaxTimestamp(table, column, dbConnection)
+		if latest.IsZero() {
+			check.fail("", fmt.Sprintf("%s has no %s values", table.name, column))
+			continue
+		}
+		age := time.Since(latest)
+		if age.Hours() > check.rule.Hours {
+			check.fail(latest.Format(time.RFC3339), fmt.Sprintf("The newest %s is %.1f hours old, more than %v", column, age.Hours(), check.rule.Hours))
+		}
+	}
+}
+
+func (checker *qualityChecker) observe(values []interface{}, row []string) {
+	checker.report.Rows++
+	for _, check := range checker.checks {
+		if check.rule.Check != "freshness" {
+			check.observe(values, row)
+		}
+	}
+}
+
+func (check *ruleCheck) observe(values []interface{}, row []string) {
+	for _, index := range check.indexes {
+		if index < 0 {
+			return
+		}
+	}
+
+	switch check.rule.Check {
+		case "notnull":
+			if values[check.indexes[0]] == nil {
+				check.fail("", "")
+			}
+		case "unique":
+			key := make([]string, 0)
+			for _, index := range check.indexes {
+				key = append(key, row[index])
+			}
+			joined := strings.Join(key, "\x1f")
+			if check.seen[joined] {
+				check.fail(strings.Join(key, ", "), "")
+			}
+			check.seen[joined] = true
+		case "allowed":
+			index := check.indexes[0]
+			if values[index] != nil && !check.allowed[row[index]] {
+				check.fail(row[index], "")
+			}
+		case "regex":
+			index := check.indexes[0]
+			if values[index] != nil && !check.pattern.MatchString(row[index]) {
+				check.fail(row[index], "")
+			}
+		case "range":
+			index := check.indexes[0]
+			if values[index] != nil && !inRange(row[index], check.rule.Min, check.rule.Max) {
+				check.fail(row[index], "")
+			}
+	}
+}
+
+// Record a failure, keeping the first few distinct values as examples.
+func (check *ruleCheck) fail(value string, message string) {
+	check.result.Passed = false
+	check.result.Failures++
+	if message != "" {
+		check.result.Message = message
+	}
+	if value != "" && len(check.result.Examples) < qualityExamples {
+		for _, example := range check.result.Examples {
+			if example == value {
+				return
+			}
+		}
+		check.result.Examples = append(check.result.Examples, value)
+	}
+}
+
+// Whether a value is within the bounds, compared as numbers when they are, otherwise as text.
+func inRange(value string, min string, max string) bool {
+	compare := func(a string, b string) int {
+		aNumber, aErr := strconv.ParseFloat(a, 64)
+		bNumber, bErr := strconv.ParseFloat(b, 64)
+		if aErr == nil && bErr == nil {
+			switch {
+				case aNumber < bNumber:
+					return -1
+				case aNumber > bNumber:
+					return 1
+			}
+			return 0
+		}
+		return strings.Compare(a, b)
+	}
+	if min != "" && compare(value, min) < 0 {
+		return false
+	}
+	if max != "" && compare(value, max) > 0 {
+		return false
+	}
+	return true
+}
+
+// Write the quality report out to disk once the extract is done.
+func (checker *qualityChecker) finish(entry *ManifestEntry) {
+	checker.report.Generated = time.Now()
+	for _, check := range checker.checks {
+		if !check.result.Passed && check.result.Message == "" {
+			check.result.Message = fmt.Sprintf("%d rows failed the %s check on %s", check.result.Failures, check.rule.Check, strings.Join(check.result.Columns, ", "))
+		}
+		checker.report.Results = append(checker.report.Results, check.result)
+	}
+	writeJSON(checker.path, checker.report)
+	if entry != nil {
+		entry.Quality = filepath.Base(filepath.Dir(checker.path)) + string(filepath.Separator) + filepath.Base(checker.path)
+	}
+
+	qualityLock.Lock()
+	defer qualityLock.Unlock()
+	qualityReports = append(qualityReports, checker.report)
+}
+
+// Flags for the failed rules, warnings are only logged.
+func qualityFlags(reports []QualityReport) []Flag {
+	flags := make([]Flag, 0)
+	for _, report := range reports {
+		for _, result := range report.Results {
+			if result.Passed {
+				continue
+			}
+			if result.Severity == "warn" {
+				log.Println(fmt.Sprintf("Quality warning on %s: %s", report.Table, result.Message))
+				continue
+			}
+			flags = append(flags, Flag{
+				Table: report.Table,
+				Check: result.Check,
+				Actual: result.Failures,
+				Message: fmt.Sprintf("%s: %s", report.Table, result.Message),
+			})
+		}
+	}
+	return flags
+}
\ No newline at end of file
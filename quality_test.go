@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQualityRules(t *testing.T) {
+	rules := []RuleConfig{
+		{Table: "INCIDENTSM1", Column: "INCIDENT_ID", Check: "notnull"},
+		{Table: "INCIDENTSM1", Columns: []string{"INCIDENT_ID"}, Check: "unique"},
+		{Table: "INCIDENTSM1", Column: "STATUS", Check: "allowed", Values: []string{"Open", "Closed"}, Severity: "warn"},
+		{Table: "INCIDENTSM1", Column: "INCIDENT_ID", Check: "regex", Pattern: "^IM[0-9]+$"},
+		{Table: "INCIDENTSM1", Column: "PRIORITY", Check: "range", Min: "1", Max: "5"},
+		{Table: "INCIDENTSM1", Column: "MISSING", Check: "notnull"},
+		{Table: "LOCM1", Column: "LOCATION", Check: "notnull"},
+	}
+	if err := validateRules(rules); err != nil {
+		t.Fatal("Rules should be valid", err)
+	}
+	if err := validateRules([]RuleConfig{{Table: "INCIDENTSM1", Check: "freshness"}}); err == nil {
+		t.Fatal("Freshness without hours should be refused")
+	}
+
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	table := Table{
+		name: "INCIDENTSM1",
+		columns: []Column{
+			testColumn("INCIDENT_ID", "nvarchar", "120", "0", "0", "true"),
+			testColumn("STATUS", "nvarchar", "60", "0", "0", "true"),
+			testColumn("PRIORITY", "nvarchar", "10", "0", "0", "true"),
+		},
+	}
+	tableRules := tableRules(rules, "incidentsm1")
+	if len(tableRules) != 6 {
+		t.Fatal("Rules should match the table ignoring case", tableRules)
+	}
+
+	checker := newQualityChecker(table, tableRules, filepath.Join(directory, "INCIDENTSM1.json"))
+	rows := [][]interface{}{
+		{"IM1", "Open", "1"},
+		{"IM1", "Pending", "10"},
+		{nil, "Closed", "2"},
+		{"SD3", nil, nil},
+	}
+	for _, values := range rows {
+		row := make([]string, 0)
+		for _, value := range values {
+			row = append(row, formatValue(value))
+		}
+		checker.observe(values, row)
+	}
+	entry := ManifestEntry{}
+	checker.finish(&entry)
+
+	failures := make([]int, 0)
+	for _, result := range checker.report.Results {
+		failures = append(failures, result.Failures)
+	}
+	expected := []int{1, 1, 1, 1, 1, 0}
+	for index := range expected {
+		if failures[index] != expected[index] {
+			t.Fatal("Unexpected rule failures", failures)
+		}
+	}
+	if checker.report.Results[5].Passed || entry.Quality != filepath.Join(filepath.Base(directory), "INCIDENTSM1.json") {
+		t.Fatal("A rule on a missing column should fail", checker.report.Results[5])
+	}
+
+	// The warning is only logged, the rest fail the run.
+	flags := qualityFlags([]QualityReport{checker.report})
+	if len(flags) != 5 {
+		t.Fatal("Unexpected quality flags", flags)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The watermark handed to a query on a full load, the earliest datetime.
+var queryEpoch = time.Date(1753, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Check the queries before starting.
+func validateQueries(queries []QueryConfig, tables []string) error {
+	names := make(map[string]bool)
+	for _, table := range tables {
+		names[nameKey(table)] = true
+	}
+	for _, query := range queries {
+		if query.Name == "" || query.SQL == "" {
+			return fmt.Errorf("Every query needs a name and sql")
+		}
+		if strings.ContainsAny(query.Name, `/\`) {
+			return fmt.Errorf("The query name %s can not contain a path", query.Name)
+		}
+		if names[nameKey(query.Name)] {
+			return fmt.Errorf("The query name %s is already used by a table or query", query.Name)
+		}
+		names[nameKey(query.Name)] = true
+	}
+	return nil
+}
+
+// The named parameters of a query, with the watermark the query can filter on.
+func queryParameters(query QueryConfig, since time.Time) []interface{} {
+	names := make([]string, 0)
+	for name := range query.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parameters := make([]interface{}, 0)
+	for _, name := range names {
+		parameters = append(parameters, sql.Named(name, query.Parameters[name]))
+	}
+	if query.Watermark != "" {
+		if since.IsZero() {
+			since = queryEpoch
+		}
+		parameters = append(parameters, sql.Named("watermark", since))
+	}
+	return parameters
+}
+
+// The parameter types sp_describe_first_result_set is told about, by the Go type of the config value.
+func parameterType(value interface{}) string {
+	switch value.(type) {
+		case bool:
+			return "bit"
+		case float64:
+			return "float"
+		case time.Time:
+			return "datetime2"
+		default:
+			return "nvarchar(max)"
+	}
+}
+
+// The @params declaration of a query for sp_describe_first_result_set.
+func queryDeclarations(query QueryConfig) string {
+	declarations := make([]string, 0)
+	for _, parameter := range queryParameters(query, time.Time{}) {
+		named := parameter.(sql.NamedArg)
+		declarations = append(declarations, "@" + named.Name + " " + parameterType(named.Value))
+	}
+	return strings.Join(declarations, ", ")
+}
+
+// Build the table for a query, describing its result set without running it.
+func getQueryMetadata(query QueryConfig, dbConnection* sql.DB) (Table, error) {
+
+	rows, err := dbConnection.Query("EXEC sp_describe_first_result_set @tsql, @params",
+		sql.Named("tsql", query.SQL),
+		sql.Named("params", queryDeclarations(query)),
+	)
+	if err != nil {
+		return Table{}, fmt.Errorf("Query %s: %v", query.Name, err)
+	}
+	defer rows.Close()
+
+	// The procedure has grown columns over the SQL Server versions, so they are read by name.
+	names, err := rows.Columns()
+	if err != nil {
+		return Table{}, fmt.Errorf("Query %s: %v", query.Name, err)
+	}
+
+	table := Table{
+		name: query.Name,
+		kind: "query",
+		query: query.SQL,
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(names))
+		pointers := make([]interface{}, len(names))
+		for index := range values {
+			pointers[index] = &values[index]
+		}
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return Table{}, fmt.Errorf("Query %s: %v", query.Name, err)
+		}
+
+		described := make(map[string]sql.NullString)
+		for index, name := range names {
+			described[name] = values[index]
+		}
+
+		// Hidden columns are only returned for browse mode.
+		if described["is_hidden"].String == "true" {
+			continue
+		}
+		table.columns = append(table.columns, describedColumn(described))
+	}
+	if err = rows.Err(); err != nil {
+		return Table{}, fmt.Errorf("Query %s: %v", query.Name, err)
+	}
+
+	if query.Watermark != "" {
+		watermark, ok := columnName(table, query.Watermark)
+		if !ok {
+			return Table{}, fmt.Errorf("Query %s has no %s column for its watermark", query.Name, query.Watermark)
+		}
+		table.timestamp = watermark
+	}
+
+	return table, nil
+}
+
+// Turn a row of sp_describe_first_result_set into the column model, which reports lengths in bytes and -1 for MAX like sys.columns.
+func describedColumn(described map[string]sql.NullString) Column {
+	text := func(value string) sql.NullString {
+		return sql.NullString{String: value, Valid: true}
+	}
+
+	// The system type name carries the length, such as nvarchar(60).
+	dataType := described["system_type_name"].String
+	if bracket := strings.Index(dataType, "("); bracket >= 0 {
+		dataType = dataType[:bracket]
+	}
+
+	return Column{
+		name: described["name"],
+		dataType: text(strings.ToLower(strings.TrimSpace(dataType))),
+		maxLength: described["max_length"],
+		precision: described["precision"],
+		scale: described["scale"],
+		nullable: text(strconv.FormatBool(described["is_nullable"].String != "false")),
+		ordinalPosition: described["column_ordinal"],
+		collationName: described["collation_name"],
+		primaryKey: text("false"),
+		identity: text("false"),
+		computed: text("false"),
+	}
+}
+
+// Add the newest watermark of each query extract to the delta file, keeping the previous one when nothing new came back.
+func appendQueryDeltas(queries []Table, entries []ManifestEntry, deltaMap map[string]time.Time, folder string) {
+
+	written := make(map[string]ManifestEntry)
+	for _, entry := range entries {
+		written[entry.Table] = entry
+	}
+
+	outFile, err := os.OpenFile(folder + string(filepath.Separator) + "delta.csv", os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer outFile.Close()
+
+	writer := csv.NewWriter(outFile)
+	for _, query := range queries {
+		if query.timestamp == "" {
+			continue
+		}
+		entry, ok := written[query.name]
+		latest := deltaMap[query.name].Format(time.RFC3339)
+		if ok && entry.Watermark != nil {
+			latest = entry.Watermark.Max
+		} else if deltaMap[query.name].IsZero() {
+			continue
+		}
+		writer.Write([]string{
+			query.name,
+			query.timestamp,
+			latest,
+			strconv.Itoa(entry.Rows),
+		})
+	}
+	writer.Flush()
+}
\ No newline at end of file
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A row of sp_describe_first_result_set with the columns the metadata is read from.
+func testDescribed(name string, systemType string, maxLength string, precision string, scale string, nullable string) map[string]sql.NullString {
+	text := func(value string) sql.NullString {
+		return sql.NullString{String: value, Valid: true}
+	}
+	return map[string]sql.NullString{
+		"is_hidden": text("false"),
+		"name": text(name),
+		"system_type_name": text(systemType),
+		"max_length": text(maxLength),
+		"precision": text(precision),
+		"scale": text(scale),
+		"is_nullable": text(nullable),
+	}
+}
+
+func TestDescribedColumns(t *testing.T) {
+	described := []map[string]sql.NullString{
+		testDescribed("ASSIGNMENT", "nvarchar(60)", "120", "0", "0", "false"),
+		testDescribed("NOTES", "nvarchar(max)", "-1", "0", "0", "true"),
+		testDescribed("CODE", "varchar(max)", "-1", "0", "0", "true"),
+		testDescribed("TOTAL", "decimal(12,2)", "9", "12", "2", "false"),
+		testDescribed("OPENED", "datetime", "8", "23", "3", "true"),
+		testDescribed("ACTION", "ntext", "16", "0", "0", "true"),
+	}
+	expected := []string{
+		"[ASSIGNMENT] [nvarchar](60) NOT NULL",
+		"[NOTES] [nvarchar](MAX) NULL",
+		"[CODE] [varchar](MAX) NULL",
+		"[TOTAL] [decimal](12, 2) NOT NULL",
+		"[OPENED] [datetime] NULL",
+		"[ACTION] [ntext] NULL",
+	}
+
+	table := Table{name: "open_by_group"}
+	for _, row := range described {
+		table.columns = append(table.columns, describedColumn(row))
+	}
+
+	definition := sqlServerDefinition(table)
+	for index, column := range definition.columns {
+		if column != expected[index] {
+			t.Fatal("Unexpected column definition", column, expected[index])
+		}
+	}
+}
+
+func TestQueryDeclarations(t *testing.T) {
+	query := QueryConfig{
+		Parameters: map[string]interface{}{"status": "t", "limit": float64(10), "open": true},
+		Watermark: "SYSMODTIME",
+	}
+	if declarations := queryDeclarations(query); declarations != "@limit float, @open bit, @status nvarchar(max), @watermark datetime2" {
+		t.Fatal("Unexpected parameter declarations", declarations)
+	}
+	if declarations := queryDeclarations(QueryConfig{}); declarations != "" {
+		t.Fatal("A query without parameters declares none", declarations)
+	}
+}
+
+func TestQueryParameters(t *testing.T) {
+	query := QueryConfig{Name: "open_by_group", Parameters: map[string]interface{}{"status": "open", "days": 30.0}, Watermark: "SYSMODTIME"}
+
+	parameters := queryParameters(query, time.Time{})
+	if len(parameters) != 3 || parameters[0].(sql.NamedArg).Name != "days" || parameters[2].(sql.NamedArg).Value != queryEpoch {
+		t.Fatal("Full loads should start from the earliest datetime", parameters)
+	}
+
+	since := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	parameters = queryParameters(query, since)
+	if parameters[2].(sql.NamedArg).Name != "watermark" || parameters[2].(sql.NamedArg).Value != since {
+		t.Fatal("Deltas should start from the previous watermark", parameters)
+	}
+
+	if validateQueries([]QueryConfig{{Name: "INCIDENTSM1", SQL: "SELECT 1"}}, []string{"INCIDENTSM1"}) == nil {
+		t.Fatal("A query named like a table should be refused")
+	}
+}
+
+func TestQueryCatalogFiles(t *testing.T) {
+	query := Table{name: "open_by_group", kind: "query", compression: "gzip"}
+	files := catalogTable(query, nil, true).Files
+	if files.Data != filepath.Join("queries", "open_by_group.csv.gz") || files.Describe != filepath.Join("describe", "open_by_group.sql") {
+		t.Fatal("A query should be listed with its extract and describe", files)
+	}
+	if files.Constraints != "" || files.Indexes != "" {
+		t.Fatal("A query has no constraints or indexes", files)
+	}
+
+	entity := Table{name: "assignment", kind: "entity", compression: "gzip"}
+	if files = catalogTable(entity, nil, true).Files; files.Data != "" {
+		t.Fatal("An entity without rows is not extracted", files)
+	}
+	entity.rowCount = 10
+	if files = catalogTable(entity, nil, true).Files; files.Data != filepath.Join("entities", "assignment.csv.gz") {
+		t.Fatal("An entity should be listed with its extract", files)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+)
+
+// Row count history kept in the state store.
+const stateHistory = 30
+
+// Typedef for the state kept between runs, written out as results/state.json
+type State struct {
+	Tables map[string][]RowCountHistory `json:"tables"`
+	Drift map[string]string `json:"drift"`
+}
+
+// Typedef for the row count of a table in a run
+type RowCountHistory struct {
+	Run string `json:"run"`
+	RowCount int `json:"rowCount"`
+}
+
+// Typedef for the run summary, written out as summary.json
+type Summary struct {
+	Run string `json:"run"`
+	Command string `json:"command"`
+	Started time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Tables int `json:"tables"`
+	Rows int `json:"rows"`
+	Flags []Flag `json:"flags"`
+}
+
+// Typedef for something in a run which needs looking at
+type Flag struct {
+	Table string `json:"table"`
+	Check string `json:"check"`
+	Expected int `json:"expected"`
+	Actual int `json:"actual"`
+	Message string `json:"message"`
+}
+
+// Load the state store, which is empty before the first run.
+func loadState(path string) (*State, error) {
+	state := State{Tables: make(map[string][]RowCountHistory), Drift: make(map[string]string)}
+
+	stateFile, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(stateFile, &state)
+	if err != nil {
+		return nil, err
+	}
+	if state.Tables == nil {
+		state.Tables = make(map[string][]RowCountHistory)
+	}
+	if state.Drift == nil {
+		state.Drift = make(map[string]string)
+	}
+	return &state, nil
+}
+
+// The row count of a table in the last run before this one.
+func (state *State) previous(table string, run string) (RowCountHistory, bool) {
+	history := state.Tables[table]
+	for index := len(history) - 1; index >= 0; index-- {
+		if history[index].Run != run {
+			return history[index], true
+		}
+	}
+	return RowCountHistory{}, false
+}
+
+// Add this run's row counts, replacing an earlier run from the same day.
+func (state *State) record(run string, tables []Table) {
+	for _, table := range tables {
+		history := make([]RowCountHistory, 0)
+		for _, entry := range state.Tables[table.name] {
+			if entry.Run != run {
+				history = append(history, entry)
+			}
+		}
+		history = append(history, RowCountHistory{Run: run, RowCount: table.rowCount})
+		if len(history) > stateHistory {
+			history = history[len(history) - stateHistory:]
+		}
+		state.Tables[table.name] = history
+	}
+}
+
+// Compare the counted rows with the rows written for full loads, and with the previous run for every table.
+func reconcile(tables []Table, entries []ManifestEntry, state *State, run string, config ReconcileConfig) []Flag {
+	flags := make([]Flag, 0)
+
+	exported := make(map[string]ManifestEntry)
+	for _, entry := range entries {
+		exported[entry.Table] = entry
+	}
+
+	for _, table := range tables {
+
+		// Deltas write a part of the table, so only full loads can be reconciled.
+		entry, ok := exported[table.name]
+		if ok && entry.Since == "" && outside(table.rowCount, entry.Rows, *config.Tolerance) {
+			flags = append(flags, Flag{
+				Table: table.name,
+				Check: "reconcile",
+				Expected: table.rowCount,
+				Actual: entry.Rows,
+				Message: fmt.Sprintf("%s counted %d rows but wrote %d", table.name, table.rowCount, entry.Rows),
+			})
+		}
+
+		previous, ok := state.previous(table.name, run)
+		if !ok || previous.RowCount == 0 {
+			continue
+		}
+		// A limit of 0 flags any change, but an unchanged count is never flagged.
+		change := float64(table.rowCount - previous.RowCount) / float64(previous.RowCount) * 100
+		if change < 0 && change <= -*config.Drop {
+			flags = append(flags, Flag{
+				Table: table.name,
+				Check: "drop",
+				Expected: previous.RowCount,
+				Actual: table.rowCount,
+				Message: fmt.Sprintf("%s dropped %.0f%% from %d rows in %s to %d", table.name, -change, previous.RowCount, previous.Run, table.rowCount),
+			})
+		} else if change > 0 && change >= *config.Spike {
+			flags = append(flags, Flag{
+				Table: table.name,
+				Check: "spike",
+				Expected: previous.RowCount,
+				Actual: table.rowCount,
+				Message: fmt.Sprintf("%s grew %.0f%% from %d rows in %s to %d", table.name, change, previous.RowCount, previous.Run, table.rowCount),
+			})
+		}
+	}
+
+	return flags
+}
+
+// Whether two row counts differ by more than a percentage tolerance.
+func outside(expected int, actual int, tolerance float64) bool {
+	if expected == actual {
+		return false
+	}
+	if expected == 0 {
+		return true
+	}
+	return math.Abs(float64(actual - expected)) / float64(expected) * 100 > tolerance
+}
\ No newline at end of file
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	tolerance, drop, spike := 1.0, 50.0, 100.0
+	config := ReconcileConfig{Tolerance: &tolerance, Drop: &drop, Spike: &spike}
+	state := &State{Tables: map[string][]RowCountHistory{
+		"INCIDENTSM1": {{Run: "2018_06_30", RowCount: 1000}, {Run: "2018_07_01", RowCount: 1000}},
+		"PROBSUMMARYM1": {{Run: "2018_07_01", RowCount: 100}},
+		"LOCM1": {{Run: "2018_07_01", RowCount: 50}},
+	}}
+	tables := []Table{
+		{name: "INCIDENTSM1", rowCount: 400},
+		{name: "PROBSUMMARYM1", rowCount: 250},
+		{name: "LOCM1", rowCount: 50},
+		{name: "CM3RM1", rowCount: 200},
+	}
+	entries := []ManifestEntry{
+		{Table: "INCIDENTSM1", Since: "2018-07-01T00:00:00Z", Rows: 20},
+		{Table: "PROBSUMMARYM1", Rows: 250},
+		{Table: "LOCM1", Rows: 40},
+		{Table: "CM3RM1", Rows: 201},
+	}
+
+	// A rerun on the same day compares with the run before.
+	state.record("2018_07_01", []Table{{name: "INCIDENTSM1", rowCount: 990}})
+	if len(state.Tables["INCIDENTSM1"]) != 2 {
+		t.Fatal("Same day run should replace the earlier entry", state.Tables["INCIDENTSM1"])
+	}
+
+	flags := reconcile(tables, entries, state, "2018_07_02", config)
+	checks := make(map[string]string)
+	for _, flag := range flags {
+		checks[flag.Table] = flag.Check
+	}
+	if len(flags) != 3 || checks["INCIDENTSM1"] != "drop" || checks["PROBSUMMARYM1"] != "spike" || checks["LOCM1"] != "reconcile" {
+		t.Fatal("Unexpected flags", flags)
+	}
+
+	// A tolerance of 0 is kept, so any difference is flagged.
+	tolerance = 0
+	flags = reconcile(tables, entries, state, "2018_07_02", config)
+	if len(flags) != 4 || flags[3].Table != "CM3RM1" || flags[3].Check != "reconcile" {
+		t.Fatal("A tolerance of 0 should flag any difference", flags)
+	}
+	tolerance = 1
+
+	// Limits of 0 flag any drop or growth, but never an unchanged table.
+	drop, spike = 0, 0
+	state = &State{Tables: map[string][]RowCountHistory{
+		"INCIDENTSM1": {{Run: "2018_07_01", RowCount: 100}},
+		"PROBSUMMARYM1": {{Run: "2018_07_01", RowCount: 100}},
+		"LOCM1": {{Run: "2018_07_01", RowCount: 100}},
+	}}
+	tables = []Table{
+		{name: "INCIDENTSM1", rowCount: 99},
+		{name: "PROBSUMMARYM1", rowCount: 101},
+		{name: "LOCM1", rowCount: 100},
+	}
+	flags = reconcile(tables, nil, state, "2018_07_02", config)
+	checks = make(map[string]string)
+	for _, flag := range flags {
+		checks[flag.Table] = flag.Check
+	}
+	if len(flags) != 2 || checks["INCIDENTSM1"] != "drop" || checks["PROBSUMMARYM1"] != "spike" {
+		t.Fatal("Limits of 0 should flag any change and nothing else", flags)
+	}
+}
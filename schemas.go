@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// JSON object properties which keep the column order when marshalled.
+type orderedProperties struct {
+	names []string
+	values []interface{}
+}
+
+func (properties orderedProperties) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	for index, name := range properties.names {
+		if index > 0 {
+			buffer.WriteString(",")
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(properties.values[index])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteString(":")
+		buffer.Write(value)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes(), nil
+}
+
+// Typedef for a JSON Schema document
+type jsonSchema struct {
+	Schema string `json:"$schema"`
+	Title string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Type string `json:"type"`
+	Properties orderedProperties `json:"properties"`
+	Required []string `json:"required"`
+	AdditionalProperties bool `json:"additionalProperties"`
+}
+
+// Typedef for an Avro record schema
+type avroSchema struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Doc string `json:"doc,omitempty"`
+	Fields []avroField `json:"fields"`
+}
+
+// Typedef for an Avro record field
+type avroField struct {
+	Name string `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	Doc string `json:"doc,omitempty"`
+	Type interface{} `json:"type"`
+	Default *json.RawMessage `json:"default,omitempty"`
+}
+
+// Typedef for a Spark StructType
+type sparkSchema struct {
+	Type string `json:"type"`
+	Fields []sparkField `json:"fields"`
+}
+
+// Typedef for a Spark StructField
+type sparkField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Nullable bool `json:"nullable"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// Characters which are not allowed in Avro names.
+var avroInvalid = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// Avro null default for nullable fields.
+var avroNull = json.RawMessage("null")
+
+func writeSchemas(tables []Table, folder string, namespace string) {
+	for _, table := range tables {
+		path := folder + string(filepath.Separator) + table.name
+
+		writeJSON(path + ".schema.json", tableJSONSchema(table))
+		writeJSON(path + ".avsc", tableAvroSchema(table, namespace))
+		writeJSON(path + ".spark.json", tableSparkSchema(table))
+	}
+}
+
+// Marshal a value as indented JSON and write it out to disk.
+func writeJSON(path string, value interface{}) {
+	content, err := json.MarshalIndent(value, "", "\t")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	err = ioutil.WriteFile(path, content, 0666)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func tableJSONSchema(table Table) jsonSchema {
+
+	schema := jsonSchema{
+		Schema: "http://json-schema.org/draft-07/schema#",
+		Title: table.name,
+		Description: table.description,
+		Type: "object",
+		Required: make([]string, 0),
+	}
+
+	for _, column := range table.columns {
+		property := jsonSchemaType(column)
+		if column.description.String != "" {
+			property["description"] = column.description.String
+		}
+		if column.nullable.String != "false" {
+			property["type"] = []interface{}{property["type"], "null"}
+		} else {
+			schema.Required = append(schema.Required, column.name.String)
+		}
+		schema.Properties.names = append(schema.Properties.names, column.name.String)
+		schema.Properties.values = append(schema.Properties.values, property)
+	}
+
+	return schema
+}
+
+// The JSON Schema property describing a column.
+func jsonSchemaType(column Column) map[string]interface{} {
+	switch column.dataType.String {
+		case "bit":
+			return map[string]interface{}{"type": "boolean"}
+		case "tinyint":
+			return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 255}
+		case "smallint":
+			return map[string]interface{}{"type": "integer", "minimum": -32768, "maximum": 32767}
+		case "int":
+			return map[string]interface{}{"type": "integer", "minimum": -2147483648, "maximum": 2147483647}
+		case "bigint":
+			return map[string]interface{}{"type": "integer"}
+		case "decimal", "numeric", "money", "smallmoney", "float", "real":
+			return map[string]interface{}{"type": "number"}
+		case "date":
+			return map[string]interface{}{"type": "string", "format": "date"}
+		case "datetime", "datetime2", "datetimeoffset", "smalldatetime":
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		case "time":
+			return map[string]interface{}{"type": "string", "format": "time"}
+		case "uniqueidentifier":
+			return map[string]interface{}{"type": "string", "format": "uuid"}
+		case "binary", "varbinary", "image", "timestamp":
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		case "char", "nchar", "varchar", "nvarchar":
+			property := map[string]interface{}{"type": "string"}
+			if length, err := strconv.Atoi(typeLength(column)); err == nil {
+				property["maxLength"] = length
+			}
+			return property
+		default:
+			return map[string]interface{}{"type": "string"}
+	}
+}
+
+func tableAvroSchema(table Table, namespace string) avroSchema {
+
+	schema := avroSchema{
+		Type: "record",
+		Name: avroName(table.name),
+		Namespace: avroName(namespace),
+		Doc: table.description,
+		Fields: make([]avroField, 0),
+	}
+
+	for _, column := range table.columns {
+		field := avroField{
+			Name: avroName(column.name.String),
+			Doc: column.description.String,
+			Type: avroType(column),
+		}
+
+		// Keep the real column name when it had to be changed.
+		if field.Name != column.name.String {
+			field.Aliases = []string{column.name.String}
+		}
+
+		if column.nullable.String != "false" {
+			field.Type = []interface{}{"null", field.Type}
+			field.Default = &avroNull
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+
+	return schema
+}
+
+// Replace anything Avro does not allow in a name.
+func avroName(name string) string {
+	name = avroInvalid.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// The Avro type describing a column.
+func avroType(column Column) interface{} {
+	switch column.dataType.String {
+		case "bit":
+			return "boolean"
+		case "tinyint", "smallint", "int":
+			return "int"
+		case "bigint":
+			return "long"
+		case "real":
+			return "float"
+		case "float":
+			if column.precision.String == "24" {
+				return "float"
+			}
+			return "double"
+		case "decimal", "numeric", "money", "smallmoney":
+			precision, _ := strconv.Atoi(column.precision.String)
+			scale, _ := strconv.Atoi(column.scale.String)
+			return map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": precision, "scale": scale}
+		case "date":
+			return map[string]interface{}{"type": "int", "logicalType": "date"}
+		case "datetime", "smalldatetime":
+			return map[string]interface{}{"type": "long", "logicalType": "local-timestamp-millis"}
+		case "datetime2":
+			return map[string]interface{}{"type": "long", "logicalType": "local-timestamp-micros"}
+		case "datetimeoffset":
+			return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}
+		case "time":
+			return map[string]interface{}{"type": "long", "logicalType": "time-micros"}
+		case "uniqueidentifier":
+			return map[string]interface{}{"type": "string", "logicalType": "uuid"}
+		case "binary", "varbinary", "image", "timestamp":
+			return "bytes"
+		default:
+			return "string"
+	}
+}
+
+func tableSparkSchema(table Table) sparkSchema {
+
+	schema := sparkSchema{
+		Type: "struct",
+		Fields: make([]sparkField, 0),
+	}
+
+	for _, column := range table.columns {
+		field := sparkField{
+			Name: column.name.String,
+			Type: sparkType(column),
+			Nullable: column.nullable.String != "false",
+			Metadata: map[string]interface{}{},
+		}
+
+		// Spark keeps column descriptions as the comment metadata.
+		if column.description.String != "" {
+			field.Metadata["comment"] = column.description.String
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+
+	return schema
+}
+
+// The Spark SQL type describing a column.
+func sparkType(column Column) string {
+	switch column.dataType.String {
+		case "bit":
+			return "boolean"
+		case "tinyint", "smallint":
+			return "short"
+		case "int":
+			return "integer"
+		case "bigint":
+			return "long"
+		case "real":
+			return "float"
+		case "float":
+			if column.precision.String == "24" {
+				return "float"
+			}
+			return "double"
+		case "decimal", "numeric":
+			return "decimal(" + column.precision.String + "," + column.scale.String + ")"
+		case "money":
+			return "decimal(19,4)"
+		case "smallmoney":
+			return "decimal(10,4)"
+		case "date":
+			return "date"
+		case "datetime", "datetime2", "datetimeoffset", "smalldatetime":
+			return "timestamp"
+		case "binary", "varbinary", "image", "timestamp":
+			return "binary"
+		default:
+			return "string"
+	}
+}
\ No newline at end of file
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Typedef for a table found in the database
+type TableName struct {
+	schema string
+	name string
+	kind string
+	base string
+}
+
+// The kind of each sys.objects type which can be extracted, tables have none.
+var objectKinds = map[string]string{
+	"U": "",
+	"V": "view",
+	"SN": "synonym",
+	"IF": "function",
+	"TF": "function",
+}
+
+// Typedef for an include or exclude pattern, globs are turned into expressions as well
+type tablePattern struct {
+	text string
+	expression *regexp.Regexp
+	qualified bool
+}
+
+// Bracket quote a SQL Server identifier, doubling any closing bracket in the name.
+func quoteName(name string) string {
+	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
+}
+
+// The name a table goes by in the results and the config, dbo tables keep their bare name so earlier runs still line up.
+func (table TableName) identity() string {
+	if table.schema == "" || sameName("dbo", table.schema) {
+		return table.name
+	}
+	return table.schema + "." + table.name
+}
+
+// The bracketed schema and table name for SQL Server.
+func (table TableName) sqlName() string {
+	schema := table.schema
+	if schema == "" {
+		schema = "dbo"
+	}
+	return quoteName(schema) + "." + quoteName(table.name)
+}
+
+// The schema and table of a name from the results, tables outside dbo are qualified.
+func splitIdentity(identity string) TableName {
+	parts := strings.SplitN(identity, ".", 2)
+	if len(parts) == 2 {
+		return TableName{schema: parts[0], name: parts[1]}
+	}
+	return TableName{schema: "dbo", name: identity}
+}
+
+// The bracketed name of a table in the scripts, leaving off dbo like the results do.
+func scriptName(identity string) string {
+	table := splitIdentity(identity)
+	if table.schema == "dbo" {
+		return quoteName(table.name)
+	}
+	return table.sqlName()
+}
+
+// The bracketed name a table is read from, tables without a schema are queries and entities.
+func (table Table) sqlName() string {
+	if table.schema == "" {
+		return quoteName(table.name)
+	}
+	name := TableName{schema: table.schema, name: strings.TrimPrefix(table.name, table.schema + ".")}.sqlName()
+
+	// Only functions without parameters are listed, so they are read with an empty argument list.
+	if table.kind == "function" {
+		name += "()"
+	}
+	return name
+}
+
+// Compile the include or exclude patterns, a pattern in slashes is a regular expression and anything else a glob.
+func compilePatterns(patterns []string) ([]tablePattern, error) {
+	compiled := make([]tablePattern, 0)
+	for _, text := range patterns {
+		pattern := tablePattern{text: text}
+
+		var expression string
+		if len(text) > 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+			expression = text[1:len(text) - 1]
+			pattern.qualified = true
+		} else {
+			expression = "^" + strings.Replace(strings.Replace(regexp.QuoteMeta(text), `\*`, ".*", -1), `\?`, ".", -1) + "$"
+			pattern.qualified = strings.Contains(text, ".")
+		}
+
+		var err error
+		if !collationRules.caseSensitive {
+			expression = "(?i)" + expression
+		}
+		pattern.expression, err = regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid table pattern %s: %v", text, err)
+		}
+		compiled = append(compiled, pattern)
+	}
+	return compiled, nil
+}
+
+// Match a table against a pattern, a glob without a schema matches the table in any schema.
+func (pattern tablePattern) match(table TableName) bool {
+	if pattern.qualified {
+		schema := table.schema
+		if schema == "" {
+			schema = "dbo"
+		}
+		return pattern.expression.MatchString(schema + "." + table.name)
+	}
+	return pattern.expression.MatchString(table.name)
+}
+
+// Pick the tables matching any include pattern, or every table when there are none, and then drop those matching an exclude pattern.
+func selectTables(tables []TableName, include []string, exclude []string) ([]TableName, error) {
+
+	includes, err := compilePatterns(include)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compilePatterns(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]bool, len(includes))
+	selected := make([]TableName, 0)
+	for _, table := range tables {
+		included := len(includes) == 0
+		for index, pattern := range includes {
+			if pattern.match(table) {
+				included = true
+				matched[index] = true
+			}
+		}
+		for _, pattern := range excludes {
+			if pattern.match(table) {
+				included = false
+			}
+		}
+		if included {
+			selected = append(selected, table)
+		}
+	}
+
+	for index, pattern := range includes {
+		if !matched[index] {
+			log.Println(fmt.Sprintf("The include pattern %s does not match any table", pattern.text))
+		}
+	}
+
+	return selected, nil
+}
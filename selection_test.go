@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSelectTables(t *testing.T) {
+	tables := []TableName{
+		{schema: "dbo", name: "INCIDENTSM1"},
+		{schema: "dbo", name: "SYSLOGM1"},
+		{schema: "dbo", name: "CM3RM1"},
+		{schema: "sales", name: "INCIDENTSM1"},
+		{schema: "sales", name: "Orders"},
+	}
+	identities := func(selected []TableName) []string {
+		names := make([]string, 0)
+		for _, table := range selected {
+			names = append(names, table.identity())
+		}
+		return names
+	}
+	check := func(include []string, exclude []string, expected ...string) {
+		selected, err := selectTables(tables, include, exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := identities(selected)
+		if len(names) != len(expected) {
+			t.Fatal("Unexpected tables for", include, exclude, names)
+		}
+		for index := range expected {
+			if names[index] != expected[index] {
+				t.Fatal("Unexpected tables for", include, exclude, names)
+			}
+		}
+	}
+
+	check(nil, nil, "INCIDENTSM1", "SYSLOGM1", "CM3RM1", "sales.INCIDENTSM1", "sales.Orders")
+	check([]string{"*m1"}, []string{"SYS*"}, "INCIDENTSM1", "CM3RM1", "sales.INCIDENTSM1")
+	check([]string{"dbo.INCIDENTSM1", "sales.*"}, []string{"sales.orders"}, "INCIDENTSM1", "sales.INCIDENTSM1")
+	check(nil, []string{"/^dbo\\./"}, "sales.INCIDENTSM1", "sales.Orders")
+	check([]string{"/(CM3|INCIDENTS)[RT]?M[0-9]$/"}, []string{"sales.INCIDENTS?1"}, "INCIDENTSM1", "CM3RM1")
+	check([]string{"MISSINGM1"}, nil)
+
+	_, err := selectTables(tables, []string{"/[/"}, nil)
+	if err == nil {
+		t.Fatal("An invalid expression should be an error")
+	}
+}
+
+func TestTableNames(t *testing.T) {
+	if (TableName{schema: "dbo", name: "LOCM1"}).identity() != "LOCM1" || (TableName{schema: "sales", name: "Orders"}).identity() != "sales.Orders" {
+		t.Fatal("Only tables outside dbo should be qualified")
+	}
+	if scriptName("LOCM1") != "[LOCM1]" || scriptName("sales.Orders") != "[sales].[Orders]" {
+		t.Fatal("Unexpected script names", scriptName("LOCM1"), scriptName("sales.Orders"))
+	}
+
+	table := Table{name: "sales.Orders", schema: "sales"}
+	if table.sqlName() != "[sales].[Orders]" || (Table{name: "LOCM1", schema: "dbo"}).sqlName() != "[dbo].[LOCM1]" || (Table{name: "open_incidents"}).sqlName() != "[open_incidents]" {
+		t.Fatal("Unexpected SQL names", table.sqlName())
+	}
+	if (Table{name: "sales.OpenOrders", schema: "sales", kind: "function"}).sqlName() != "[sales].[OpenOrders]()" {
+		t.Fatal("Functions are read with an empty argument list")
+	}
+	if dataFile(table) != "sales.Orders.csv.gz" {
+		t.Fatal("The file name should include the schema", dataFile(table))
+	}
+}
+
+func TestQuoteName(t *testing.T) {
+	if quoteName("Order]s") != "[Order]]s]" || quoteName("it's here") != "[it's here]" || quoteName("[x]") != "[[x]]]" {
+		t.Fatal("Unexpected quoting", quoteName("Order]s"), quoteName("[x]"))
+	}
+	if (TableName{schema: "my schema", name: "O'Brien]s"}).sqlName() != "[my schema].[O'Brien]]s]" {
+		t.Fatal("Unexpected SQL name", TableName{schema: "my schema", name: "O'Brien]s"}.sqlName())
+	}
+	if scriptName("Order Lines]") != "[Order Lines]]]" {
+		t.Fatal("Unexpected script name", scriptName("Order Lines]"))
+	}
+}
@@ -4,36 +4,23 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
-func getTables(database string, blacklist []string, dbConnection* sql.DB) ([]string) {
-
-	// Change the query with the blacklist
-	var blacklistString string
-	if len(blacklist) > 0 {
-		blacklistString += "AND TABLE_NAME NOT IN ("
-		for index, item := range blacklist {
-			if index == 0 {
-				blacklistString += "'" + item + "'"
-			} else {
-				blacklistString += ",'" + item + "'"
-			}
-		}
-		blacklistString += ")"
-	} else {
-		blacklistString = ""
-	}
+func getDatabaseInfo(dbConnection* sql.DB) (DatabaseInfo) {
 
-	queryString := fmt.Sprintf(`
+	queryString := `
 		SELECT
-			TABLE_NAME
+		    CAST(SERVERPROPERTY('ProductVersion') AS nvarchar(128)) 'Server Version',
+		    CAST(SERVERPROPERTY('Edition') AS nvarchar(128)) 'Edition',
+		    d.compatibility_level 'Compatibility Level',
+		    d.collation_name 'Collation Name'
 		FROM
-			INFORMATION_SCHEMA.TABLES
+		    sys.databases d
 		WHERE
-			TABLE_TYPE = 'BASE TABLE' AND TABLE_CATALOG = '%s'
-			%s
-	`, database, blacklistString)
+		    d.name = DB_NAME()
+	`
 
 	// Open the database query and get ready to read results.
 	query, err := dbConnection.Query(queryString)
@@ -42,23 +29,107 @@ func getTables(database string, blacklist []string, dbConnection* sql.DB) ([]str
 	}
 	defer query.Close()
 
+	var info DatabaseInfo
+
+	// Go through the results and create an array of results.
+	for query.Next() {
+		query.Scan(
+			&info.serverVersion,
+			&info.edition,
+			&info.compatibilityLevel,
+			&info.collation,
+		)
+	}
+
+	return info
+}
+
+func getTables(sources []string, dbConnection* sql.DB) ([]TableName) {
+
+	// Views, synonyms and functions are only listed when they are asked for.
+	wanted := make(map[string]bool)
+	for _, source := range sources {
+		wanted[source] = true
+	}
+
+	// Synonyms are resolved to the table or view they stand for, when it is in this database.
+	queryString := `
+		SELECT
+		    s.name 'Schema Name',
+		    o.name 'Object Name',
+		    RTRIM(o.type) 'Object Type',
+		    ISNULL(bs.name, '') 'Base Schema',
+		    ISNULL(b.name, '') 'Base Name'
+		FROM
+		    sys.objects o
+		INNER JOIN
+		    sys.schemas s ON s.schema_id = o.schema_id
+		LEFT OUTER JOIN
+		    sys.synonyms sn ON sn.object_id = o.object_id
+		LEFT OUTER JOIN
+		    sys.objects b ON b.object_id = OBJECT_ID(sn.base_object_name) AND b.type IN ('U', 'V')
+		        AND PARSENAME(sn.base_object_name, 4) IS NULL
+		        AND ISNULL(PARSENAME(sn.base_object_name, 3), DB_NAME()) = DB_NAME()
+		LEFT OUTER JOIN
+		    sys.schemas bs ON bs.schema_id = b.schema_id
+		WHERE
+		    o.is_ms_shipped = 0 AND (
+		        o.type = 'U'
+		        OR (o.type = 'V' AND @views = 1)
+		        OR (o.type = 'SN' AND @synonyms = 1)
+		        OR (o.type IN ('IF', 'TF') AND @functions = 1 AND NOT EXISTS (
+		            SELECT 1 FROM sys.parameters p WHERE p.object_id = o.object_id AND p.parameter_id > 0
+		        ))
+		    )
+		ORDER BY
+		    s.name, o.name
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString,
+		sql.Named("views", wanted["views"]),
+		sql.Named("synonyms", wanted["synonyms"]),
+		sql.Named("functions", wanted["functions"]),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
 	// Generate the slice of table names.
-	tables := make([]string, 0)
+	tables := make([]TableName, 0)
 
 	// Go through the results and create an array of results.
 	for query.Next() {
-		var column string
-		query.Scan(&column)
-		tables = append(tables, column)
+		var table TableName
+		var objectType string
+		var base TableName
+		query.Scan(&table.schema, &table.name, &objectType, &base.schema, &base.name)
+
+		table.kind = objectKinds[objectType]
+		if table.kind == "synonym" {
+			if base.name == "" {
+				log.Println(fmt.Sprintf("Skipping the synonym %s, it is not for a table or view in this database", table.identity()))
+				continue
+			}
+			table.base = base.sqlName()
+		}
+		tables = append(tables, table)
 	}
 
 	return tables
 }
 
-func getTableMetadata(tableName string, dbConnection* sql.DB) (Table) {
+func getTableMetadata(source TableName, dbConnection* sql.DB) (Table) {
 
-	queryString := fmt.Sprintf(`
-		SELECT DISTINCT
+	// Synonyms take the metadata of the object they stand for.
+	tableName := source.sqlName()
+	if source.base != "" {
+		tableName = source.base
+	}
+
+	queryString := `
+		SELECT
 		    c.name 'Column Name',
 		    t.Name 'Data Type',
 		    c.max_length 'Max Length',
@@ -67,28 +138,51 @@ func getTableMetadata(tableName string, dbConnection* sql.DB) (Table) {
 		    c.is_nullable 'Is Nullable',
 		    c.column_id 'Ordinal Position',
 		    c.collation_name 'Collation Name',
-		    ISNULL(i.is_primary_key, 0) 'Primary Key'
+		    CAST(CASE WHEN EXISTS (
+		        SELECT 1
+		        FROM sys.index_columns ic
+		        INNER JOIN sys.indexes i ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+		        WHERE i.is_primary_key = 1 AND ic.object_id = c.object_id AND ic.column_id = c.column_id
+		    ) THEN 1 ELSE 0 END AS bit) 'Primary Key',
+		    dc.name 'Default Name',
+		    dc.definition 'Default Definition',
+		    c.is_identity 'Identity',
+		    CAST(idc.seed_value AS varchar(40)) 'Identity Seed',
+		    CAST(idc.increment_value AS varchar(40)) 'Identity Increment',
+		    c.is_computed 'Computed',
+		    cc.definition 'Computed Definition',
+		    ISNULL(cc.is_persisted, 0) 'Persisted',
+		    CAST(ep.value AS nvarchar(max)) 'Description'
 		FROM    
 		    sys.columns c
 		INNER JOIN 
 		    sys.types t ON c.user_type_id = t.user_type_id
-		LEFT OUTER JOIN 
-		    sys.index_columns ic ON ic.object_id = c.object_id AND ic.column_id = c.column_id
-		LEFT OUTER JOIN 
-		    sys.indexes i ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+		LEFT OUTER JOIN
+		    sys.default_constraints dc ON dc.parent_object_id = c.object_id AND dc.parent_column_id = c.column_id
+		LEFT OUTER JOIN
+		    sys.identity_columns idc ON idc.object_id = c.object_id AND idc.column_id = c.column_id
+		LEFT OUTER JOIN
+		    sys.computed_columns cc ON cc.object_id = c.object_id AND cc.column_id = c.column_id
+		LEFT OUTER JOIN
+		    sys.extended_properties ep ON ep.class = 1 AND ep.major_id = c.object_id AND ep.minor_id = c.column_id AND ep.name = 'MS_Description'
 		WHERE
-		    c.object_id = OBJECT_ID('%s')
-	`, tableName)
+		    c.object_id = OBJECT_ID(@object)
+		ORDER BY
+		    c.column_id
+	`
 
 	// Open the database query and get ready to read results.
-	query, err := dbConnection.Query(queryString)
+	query, err := dbConnection.Query(queryString, sql.Named("object", tableName))
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer query.Close()
 
 	var table Table
-	table.name = tableName
+	table.name = source.identity()
+	table.schema = source.schema
+	table.kind = source.kind
+	table.base = source.base
 	table.type2 = false
 
 	// Go through the results and create an array of results.
@@ -104,16 +198,330 @@ func getTableMetadata(tableName string, dbConnection* sql.DB) (Table) {
 			&metadata.ordinalPosition,
 			&metadata.collationName,
 			&metadata.primaryKey,
+			&metadata.defaultName,
+			&metadata.defaultDefinition,
+			&metadata.identity,
+			&metadata.identitySeed,
+			&metadata.identityIncrement,
+			&metadata.computed,
+			&metadata.computedDefinition,
+			&metadata.persisted,
+			&metadata.description,
 		)
 		table.columns = append(table.columns, metadata)
 	}
 
+	// Get the table description.
+	table.description = getTableDescription(tableName, dbConnection)
+
+	// Get the table level constraints.
+	table.foreignKeys = getForeignKeys(tableName, dbConnection)
+	table.uniqueConstraints = getUniqueConstraints(tableName, dbConnection)
+	table.checkConstraints = getCheckConstraints(tableName, dbConnection)
+	table.indexes = getIndexes(tableName, dbConnection)
+
+	// Views and functions are described by their own definition.
+	switch table.kind {
+		case "view", "function":
+			table.definition = getModuleDefinition(tableName, dbConnection)
+		case "synonym":
+			table.definition = fmt.Sprintf("CREATE SYNONYM %s FOR %s;\n", source.sqlName(), source.base)
+	}
+
+	// The primary key index holds the key columns in key order.
+	for _, index := range table.indexes {
+		if index.primaryKey {
+			table.primaryKey = PrimaryKey{
+				name: index.name,
+				clustered: index.clustered,
+				columns: index.keyColumns,
+			}
+		}
+	}
+
 	return table
 }
 
-func getRowCount(tableName string, dbConnection* sql.DB) (int) {
+func getModuleDefinition(objectName string, dbConnection* sql.DB) (string) {
+
+	queryString := `
+		SELECT
+		    m.definition 'Definition'
+		FROM
+		    sys.sql_modules m
+		WHERE
+		    m.object_id = OBJECT_ID(@object)
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString, sql.Named("object", objectName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	var definition sql.NullString
+
+	// Go through the results and create an array of results.
+	for query.Next() {
+		query.Scan(&definition)
+	}
+
+	// Encrypted modules have no definition to show.
+	if !definition.Valid {
+		return ""
+	}
+	return strings.TrimSpace(definition.String) + "\n"
+}
+
+func getTableDescription(tableName string, dbConnection* sql.DB) (string) {
+
+	queryString := `
+		SELECT
+		    CAST(ep.value AS nvarchar(max)) 'Description'
+		FROM
+		    sys.extended_properties ep
+		WHERE
+		    ep.class = 1 AND ep.major_id = OBJECT_ID(@object) AND ep.minor_id = 0 AND ep.name = 'MS_Description'
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString, sql.Named("object", tableName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	var description string
+
+	// Go through the results and create an array of results.
+	for query.Next() {
+		query.Scan(&description)
+	}
+
+	return description
+}
+
+func getForeignKeys(tableName string, dbConnection* sql.DB) ([]ForeignKey) {
+
+	queryString := `
+		SELECT
+		    fk.name 'Constraint Name',
+		    pc.name 'Column Name',
+		    OBJECT_SCHEMA_NAME(fk.referenced_object_id) 'Referenced Schema',
+		    OBJECT_NAME(fk.referenced_object_id) 'Referenced Table',
+		    rc.name 'Referenced Column',
+		    fk.delete_referential_action_desc 'Delete Action',
+		    fk.update_referential_action_desc 'Update Action'
+		FROM
+		    sys.foreign_keys fk
+		INNER JOIN
+		    sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+		INNER JOIN
+		    sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+		INNER JOIN
+		    sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+		WHERE
+		    fk.parent_object_id = OBJECT_ID(@object)
+		ORDER BY
+		    fk.name, fkc.constraint_column_id
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString, sql.Named("object", tableName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	foreignKeys := make([]ForeignKey, 0)
+
+	// Each row is a single column of a key, so group them by the constraint name.
+	for query.Next() {
+		var name, column, referencedColumn, deleteAction, updateAction string
+		var referencedTable TableName
+		query.Scan(&name, &column, &referencedTable.schema, &referencedTable.name, &referencedColumn, &deleteAction, &updateAction)
+
+		foreignKey := ForeignKey{name: name, referencedTable: referencedTable.identity(), deleteAction: deleteAction, updateAction: updateAction}
+		foreignKeys = groupForeignKey(foreignKeys, foreignKey, column, referencedColumn)
+	}
+
+	return foreignKeys
+}
+
+// Add a key column row to the foreign keys, starting a new key when the constraint name changes.
+func groupForeignKey(foreignKeys []ForeignKey, foreignKey ForeignKey, column string, referencedColumn string) ([]ForeignKey) {
+	last := len(foreignKeys) - 1
+	if last < 0 || foreignKeys[last].name != foreignKey.name {
+		foreignKeys = append(foreignKeys, foreignKey)
+		last++
+	}
+	foreignKeys[last].columns = append(foreignKeys[last].columns, column)
+	foreignKeys[last].referencedColumns = append(foreignKeys[last].referencedColumns, referencedColumn)
+	return foreignKeys
+}
+
+func getUniqueConstraints(tableName string, dbConnection* sql.DB) ([]UniqueConstraint) {
+
+	queryString := `
+		SELECT
+		    kc.name 'Constraint Name',
+		    c.name 'Column Name'
+		FROM
+		    sys.key_constraints kc
+		INNER JOIN
+		    sys.index_columns ic ON ic.object_id = kc.parent_object_id AND ic.index_id = kc.unique_index_id
+		INNER JOIN
+		    sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+		WHERE
+		    kc.type = 'UQ' AND kc.parent_object_id = OBJECT_ID(@object)
+		ORDER BY
+		    kc.name, ic.key_ordinal
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString, sql.Named("object", tableName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	uniqueConstraints := make([]UniqueConstraint, 0)
+
+	// Each row is a single column of a constraint, so group them by the constraint name.
+	for query.Next() {
+		var name, column string
+		query.Scan(&name, &column)
+		uniqueConstraints = groupUniqueConstraint(uniqueConstraints, name, column)
+	}
+
+	return uniqueConstraints
+}
+
+// Add a column row to the unique constraints, starting a new constraint when the name changes.
+func groupUniqueConstraint(uniqueConstraints []UniqueConstraint, name string, column string) ([]UniqueConstraint) {
+	last := len(uniqueConstraints) - 1
+	if last < 0 || uniqueConstraints[last].name != name {
+		uniqueConstraints = append(uniqueConstraints, UniqueConstraint{name: name})
+		last++
+	}
+	uniqueConstraints[last].columns = append(uniqueConstraints[last].columns, column)
+	return uniqueConstraints
+}
+
+func getCheckConstraints(tableName string, dbConnection* sql.DB) ([]CheckConstraint) {
 
-	queryString := fmt.Sprintf("SELECT COUNT(*) AS 'count' FROM %s", tableName)
+	queryString := `
+		SELECT
+		    cc.name 'Constraint Name',
+		    ISNULL(COL_NAME(cc.parent_object_id, cc.parent_column_id), '') 'Column Name',
+		    cc.definition 'Definition'
+		FROM
+		    sys.check_constraints cc
+		WHERE
+		    cc.parent_object_id = OBJECT_ID(@object)
+		ORDER BY
+		    cc.name
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString, sql.Named("object", tableName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	checkConstraints := make([]CheckConstraint, 0)
+
+	// Go through the results and create an array of results.
+	for query.Next() {
+		var constraint CheckConstraint
+		query.Scan(&constraint.name, &constraint.column, &constraint.definition)
+		checkConstraints = append(checkConstraints, constraint)
+	}
+
+	return checkConstraints
+}
+
+func getIndexes(tableName string, dbConnection* sql.DB) ([]Index) {
+
+	queryString := `
+		SELECT
+		    i.name 'Index Name',
+		    i.type_desc 'Index Type',
+		    i.is_unique 'Unique',
+		    i.is_primary_key 'Primary Key',
+		    i.is_unique_constraint 'Unique Constraint',
+		    ISNULL(i.filter_definition, '') 'Filter Definition',
+		    c.name 'Column Name',
+		    ic.is_descending_key 'Descending',
+		    ic.is_included_column 'Included'
+		FROM
+		    sys.indexes i
+		INNER JOIN
+		    sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+		INNER JOIN
+		    sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+		WHERE
+		    i.object_id = OBJECT_ID(@object) AND i.type > 0 AND i.is_hypothetical = 0
+		ORDER BY
+		    i.index_id, ic.is_included_column, ic.key_ordinal, ic.index_column_id
+	`
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString, sql.Named("object", tableName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	indexes := make([]Index, 0)
+
+	// Each row is a single column of an index, so group them by the index name.
+	for query.Next() {
+		var name, indexType, filterDefinition, column string
+		var unique, primaryKey, uniqueConstraint, descending, included bool
+		query.Scan(&name, &indexType, &unique, &primaryKey, &uniqueConstraint, &filterDefinition, &column, &descending, &included)
+
+		index := Index{
+			name: name,
+			indexType: indexType,
+			clustered: strings.HasPrefix(indexType, "CLUSTERED"),
+			unique: unique,
+			primaryKey: primaryKey,
+			uniqueConstraint: uniqueConstraint,
+			filterDefinition: filterDefinition,
+		}
+		indexes = groupIndex(indexes, index, IndexColumn{name: column, descending: descending}, included)
+	}
+
+	return indexes
+}
+
+// Add a column row to the indexes, starting a new index when the name changes. Included columns have no sort direction.
+func groupIndex(indexes []Index, index Index, column IndexColumn, included bool) ([]Index) {
+	last := len(indexes) - 1
+	if last < 0 || indexes[last].name != index.name {
+		indexes = append(indexes, index)
+		last++
+	}
+
+	if included {
+		indexes[last].includedColumns = append(indexes[last].includedColumns, column.name)
+	} else {
+		indexes[last].keyColumns = append(indexes[last].keyColumns, column)
+	}
+	return indexes
+}
+
+func getRowCount(table Table, dbConnection* sql.DB) (int) {
+
+	queryString := fmt.Sprintf("SELECT COUNT(*) AS 'count' FROM %s", table.sqlName())
+
+	// Only count the rows the table settings extract, the filter is SQL from the config.
+	if table.filter != "" {
+		queryString += fmt.Sprintf(" WHERE (%s)", table.filter)
+	}
 
 	// Open the database query and get ready to read results.
 	query, err := dbConnection.Query(queryString)
@@ -133,9 +541,9 @@ func getRowCount(tableName string, dbConnection* sql.DB) (int) {
 	return count
 }
 
-func getMaxTimestamp(tableName string, timestampName string, dbConnection* sql.DB) (time.Time) {
+func getMaxTimestamp(table Table, timestampName string, dbConnection* sql.DB) (time.Time) {
 
-	queryString := fmt.Sprintf("SELECT MAX(%s) AS '%s' FROM %s", timestampName, timestampName, tableName)
+	queryString := fmt.Sprintf("SELECT MAX(%s) AS 'timestamp' FROM %s", quoteName(timestampName), table.sqlName())
 
 	// Open the database query and get ready to read results.
 	query, err := dbConnection.Query(queryString)
@@ -153,4 +561,28 @@ func getMaxTimestamp(tableName string, timestampName string, dbConnection* sql.D
 	}
 
 	return timestamp
+}
+
+func getMaxRowversion(table Table, timestampName string, dbConnection* sql.DB) (string) {
+
+	queryString := fmt.Sprintf("SELECT MAX(%s) AS 'rowversion' FROM %s", quoteName(timestampName), table.sqlName())
+
+	// Open the database query and get ready to read results.
+	query, err := dbConnection.Query(queryString)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer query.Close()
+
+	var version []byte
+
+	// Go through the results and create an array of results.
+	for query.Next() {
+		query.Scan(&version)
+	}
+
+	if len(version) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("0x%X", version)
 }
\ No newline at end of file
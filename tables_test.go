@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Read back a CSV written to the metadata folder.
+func readTestCSV(t *testing.T, path string) [][]string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal("Could not open the CSV", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal("Could not read the CSV", err)
+	}
+	return records
+}
+
+func TestGroupConstraintRows(t *testing.T) {
+
+	// Each row is one column, in key order, so a composite key keeps its column pairs lined up.
+	foreignKeys := make([]ForeignKey, 0)
+	assignment := ForeignKey{name: "ASSIGNMENTA1_GROUP", referencedTable: "dbo.ASSIGNMENTM1", deleteAction: "CASCADE", updateAction: "NO_ACTION"}
+	foreignKeys = groupForeignKey(foreignKeys, assignment, "NAME", "NAME")
+	foreignKeys = groupForeignKey(foreignKeys, assignment, "RECORD_NUMBER", "RECORD_NUMBER")
+	foreignKeys = groupForeignKey(foreignKeys, ForeignKey{name: "ASSIGNMENTA1_OPERATOR", referencedTable: "dbo.OPERATORM1"}, "MEMBERS", "NAME")
+	if len(foreignKeys) != 2 || foreignKeys[0].deleteAction != "CASCADE" || foreignKeys[1].referencedTable != "dbo.OPERATORM1" {
+		t.Fatal("Foreign key rows should be grouped by the constraint name", foreignKeys)
+	}
+	if !reflect.DeepEqual(foreignKeys[0].columns, []string{"NAME", "RECORD_NUMBER"}) || !reflect.DeepEqual(foreignKeys[0].referencedColumns, []string{"NAME", "RECORD_NUMBER"}) {
+		t.Fatal("Composite foreign key columns should keep their order", foreignKeys[0])
+	}
+	if !reflect.DeepEqual(foreignKeys[1].columns, []string{"MEMBERS"}) || !reflect.DeepEqual(foreignKeys[1].referencedColumns, []string{"NAME"}) {
+		t.Fatal("Single column foreign key has the wrong columns", foreignKeys[1])
+	}
+
+	uniqueConstraints := make([]UniqueConstraint, 0)
+	uniqueConstraints = groupUniqueConstraint(uniqueConstraints, "INCIDENTSM1_NUMBER", "NUMBER")
+	uniqueConstraints = groupUniqueConstraint(uniqueConstraints, "INCIDENTSM1_TICKET", "CATEGORY")
+	uniqueConstraints = groupUniqueConstraint(uniqueConstraints, "INCIDENTSM1_TICKET", "TICKET")
+	if len(uniqueConstraints) != 2 || !reflect.DeepEqual(uniqueConstraints[0].columns, []string{"NUMBER"}) || !reflect.DeepEqual(uniqueConstraints[1].columns, []string{"CATEGORY", "TICKET"}) {
+		t.Fatal("Unique constraint rows should be grouped by the constraint name", uniqueConstraints)
+	}
+}
+
+func TestGroupIndexRows(t *testing.T) {
+	primaryKey := Index{name: "INCIDENTSM1_PK", indexType: "CLUSTERED", clustered: true, unique: true, primaryKey: true}
+	cover := Index{name: "INCIDENTSM1_COVER", indexType: "NONCLUSTERED", filterDefinition: "([OPEN]='t')"}
+
+	// Key columns come before included columns, as the query orders them.
+	indexes := make([]Index, 0)
+	indexes = groupIndex(indexes, primaryKey, IndexColumn{name: "NUMBER"}, false)
+	indexes = groupIndex(indexes, cover, IndexColumn{name: "OPEN_TIME", descending: true}, false)
+	indexes = groupIndex(indexes, cover, IndexColumn{name: "NUMBER"}, false)
+	indexes = groupIndex(indexes, cover, IndexColumn{name: "CATEGORY", descending: true}, true)
+	indexes = groupIndex(indexes, cover, IndexColumn{name: "LOCATION"}, true)
+
+	if len(indexes) != 2 || !indexes[0].primaryKey || !reflect.DeepEqual(indexes[0].keyColumns, []IndexColumn{{name: "NUMBER"}}) || len(indexes[0].includedColumns) != 0 {
+		t.Fatal("Primary key index has the wrong columns", indexes)
+	}
+	if !reflect.DeepEqual(indexes[1].keyColumns, []IndexColumn{{name: "OPEN_TIME", descending: true}, {name: "NUMBER"}}) {
+		t.Fatal("Key columns should keep their order and sort direction", indexes[1].keyColumns)
+	}
+	if !reflect.DeepEqual(indexes[1].includedColumns, []string{"CATEGORY", "LOCATION"}) || indexes[1].filterDefinition != "([OPEN]='t')" {
+		t.Fatal("Included columns should be kept apart from the key", indexes[1])
+	}
+}
+
+func TestWriteConstraintsAndIndexes(t *testing.T) {
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	status := testColumn("STATUS", "varchar", "40", "0", "0", "true")
+	status.defaultName = sql.NullString{String: "DF_INCIDENTSM1_STATUS", Valid: true}
+	status.defaultDefinition = sql.NullString{String: "('open')", Valid: true}
+
+	table := Table{
+		name: "INCIDENTSM1",
+		columns: []Column{testColumn("NUMBER", "varchar", "60", "0", "0", "false"), status},
+		uniqueConstraints: []UniqueConstraint{{name: "INCIDENTSM1_TICKET", columns: []string{"CATEGORY", "TICKET"}}},
+		checkConstraints: []CheckConstraint{{name: "CK_INCIDENTSM1_STATUS", column: "STATUS", definition: "([STATUS]<>'')"}},
+		foreignKeys: []ForeignKey{{name: "INCIDENTSM1_LOC", columns: []string{"LOCATION"}, referencedTable: "dbo.LOCM1", referencedColumns: []string{"LOCATION"}, deleteAction: "NO_ACTION", updateAction: "CASCADE"}},
+		indexes: []Index{
+			{name: "INCIDENTSM1_PK", indexType: "CLUSTERED", clustered: true, unique: true, primaryKey: true, keyColumns: []IndexColumn{{name: "NUMBER"}}},
+			{name: "INCIDENTSM1_COVER", indexType: "NONCLUSTERED", keyColumns: []IndexColumn{{name: "OPEN_TIME", descending: true}, {name: "NUMBER"}}, includedColumns: []string{"CATEGORY", "LOCATION"}, filterDefinition: "([OPEN]='t')"},
+		},
+	}
+	writeConstraints(table, directory)
+	writeIndexes(table, directory)
+
+	constraints := readTestCSV(t, filepath.Join(directory, "INCIDENTSM1_constraints.csv"))
+	expected := [][]string{
+		{"Constraint Name", "Constraint Type", "Columns", "Referenced Table", "Referenced Columns", "Definition"},
+		{"DF_INCIDENTSM1_STATUS", "DEFAULT", "STATUS", "", "", "('open')"},
+		{"INCIDENTSM1_TICKET", "UNIQUE", "CATEGORY, TICKET", "", "", ""},
+		{"CK_INCIDENTSM1_STATUS", "CHECK", "STATUS", "", "", "([STATUS]<>'')"},
+		{"INCIDENTSM1_LOC", "FOREIGN KEY", "LOCATION", "dbo.LOCM1", "LOCATION", "ON DELETE NO_ACTION ON UPDATE CASCADE"},
+	}
+	if !reflect.DeepEqual(constraints, expected) {
+		t.Fatal("Unexpected constraints output", constraints)
+	}
+
+	indexes := readTestCSV(t, filepath.Join(directory, "INCIDENTSM1_indexes.csv"))
+	expected = [][]string{
+		{"Index Name", "Index Type", "Clustered", "Unique", "Primary Key", "Key Columns", "Included Columns", "Filter Definition"},
+		{"INCIDENTSM1_PK", "CLUSTERED", "true", "true", "true", "NUMBER", "", ""},
+		{"INCIDENTSM1_COVER", "NONCLUSTERED", "false", "false", "false", "OPEN_TIME DESC, NUMBER", "CATEGORY, LOCATION", "([OPEN]='t')"},
+	}
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Fatal("Unexpected indexes output", indexes)
+	}
+}
+
+func TestWriteMetadataColumnOrder(t *testing.T) {
+	directory, err := ioutil.TempDir("testing", "test_")
+	if err != nil {
+		t.Fatal("Could not create temp directory", err)
+	}
+	defer os.RemoveAll(directory)
+
+	number := testColumn("NUMBER", "varchar", "60", "0", "0", "false")
+	number.ordinalPosition = sql.NullString{String: "1", Valid: true}
+	number.primaryKey = sql.NullString{String: "true", Valid: true}
+	number.defaultDefinition = sql.NullString{String: "('IM0')", Valid: true}
+	writeMetadata(Table{name: "INCIDENTSM1", rowCount: 12, columns: []Column{number}}, directory)
+
+	// The original columns keep their place, the rest are added after them.
+	records := readTestCSV(t, filepath.Join(directory, "INCIDENTSM1.csv"))
+	if len(records) != 2 || len(records[0]) != len(records[1]) {
+		t.Fatal("Expected a header and one column row", records)
+	}
+	baseline := []string{"Column Name", "Data Type", "Max Length", "Precision", "Scale", "Nullable", "Ordinal Position", "Collation Name", "Primary Key", "Row Count"}
+	if !reflect.DeepEqual(records[0][:len(baseline)], baseline) || records[0][len(baseline)] != "Default" {
+		t.Fatal("New metadata columns should come after the original ones", records[0])
+	}
+	if records[1][0] != "NUMBER" || records[1][8] != "true" || records[1][9] != "12" || records[1][10] != "('IM0')" {
+		t.Fatal("Row values do not line up with the header", records[1])
+	}
+}
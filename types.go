@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Types declared with a length, rendered as MAX when sys.columns reports -1.
+var lengthTypes = map[string]bool{
+	"binary": true,
+	"char": true,
+	"nchar": true,
+	"nvarchar": true,
+	"varbinary": true,
+	"varchar": true,
+}
+
+// Types which store two bytes per character, so max_length is double the declared length.
+var unicodeTypes = map[string]bool{
+	"nchar": true,
+	"nvarchar": true,
+}
+
+// Types declared with a fractional second scale rather than a length.
+var scaleTypes = map[string]bool{
+	"datetime2": true,
+	"datetimeoffset": true,
+	"time": true,
+}
+
+// Length of a column in the units its type is declared with.
+func typeLength(column Column) string {
+	if column.maxLength.String == "-1" {
+		return "MAX"
+	}
+	if unicodeTypes[column.dataType.String] {
+		length, err := strconv.Atoi(column.maxLength.String)
+		if err == nil {
+			return strconv.Itoa(length / 2)
+		}
+	}
+	return column.maxLength.String
+}
+
+// Render the SQL Server data type of a column.
+func sqlServerType(column Column) string {
+	dataType := column.dataType.String
+
+	switch {
+		case lengthTypes[dataType]:
+			return fmt.Sprintf("%s(%s)", quoteName(dataType), typeLength(column))
+		case scaleTypes[dataType]:
+			return fmt.Sprintf("%s(%s)", quoteName(dataType), column.scale.String)
+		case dataType == "decimal" || dataType == "numeric":
+			return fmt.Sprintf("%s(%s, %s)", quoteName(dataType), column.precision.String, column.scale.String)
+		case dataType == "float" && column.precision.String != "53":
+			return fmt.Sprintf("%s(%s)", quoteName(dataType), column.precision.String)
+		default:
+			return quoteName(dataType)
+	}
+}
\ No newline at end of file
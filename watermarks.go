@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Typedef for the watermark picked for a table and how it was picked
+type watermarkChoice struct {
+	column string
+	strategy string
+	reason string
+}
+
+// The column types a watermark can be detected in, rowversion changes with every update so it always wins.
+var watermarkTypes = map[string]int{
+	"timestamp": 10,
+	"rowversion": 10,
+	"datetime2": 1,
+	"datetime": 1,
+	"datetimeoffset": 1,
+	"smalldatetime": 1,
+}
+
+// Datetime columns are only detected when their name says they change with the row.
+var modifiedName = regexp.MustCompile(`(?i)(MOD|UPDATE|CHANGE|EDIT|LAST|ALTER)`)
+
+// Pick the watermark of a table, from the table settings, then the first listed timestamp it has, then detection when asked for.
+// A table set to auto is detected first and only falls back to the list.
+func chooseWatermark(table Table, timestamps []string, override string, auto bool) watermarkChoice {
+
+	// Type 2 tables are always loaded in full.
+	if table.type2 {
+		return watermarkChoice{strategy: "type2", reason: "listed as type 2"}
+	}
+
+	switch {
+		case strings.EqualFold(override, "none"):
+			return watermarkChoice{strategy: "override", reason: "full load set in the table settings"}
+		case strings.EqualFold(override, "auto"):
+			// Detection asked for by the table comes first, the list is only a fallback.
+			if choice, ok := detectWatermark(table); ok {
+				return choice
+			}
+			auto = false
+		case override != "":
+			if column, ok := columnName(table, override); ok {
+				return watermarkChoice{column: column, strategy: "override", reason: "set in the table settings"}
+			}
+			log.Println(fmt.Sprintf("The watermark %s set for %s is not one of its columns", override, table.name))
+	}
+
+	for index, timestamp := range timestamps {
+		if column, ok := columnName(table, timestamp); ok {
+			return watermarkChoice{column: column, strategy: "priority", reason: fmt.Sprintf("timestamps entry %d", index + 1)}
+		}
+	}
+
+	if auto {
+		if choice, ok := detectWatermark(table); ok {
+			return choice
+		}
+	}
+	return watermarkChoice{strategy: "none", reason: "no watermark column"}
+}
+
+// Score the datetime and rowversion columns of a table by their name, type and index coverage, and pick the best.
+func detectWatermark(table Table) (watermarkChoice, bool) {
+
+	// Index key columns can be read without a scan, the leading column best of all.
+	leading := make(map[string]bool)
+	indexed := make(map[string]bool)
+	for _, index := range table.indexes {
+		for position, column := range index.keyColumns {
+			if position == 0 {
+				leading[column.name] = true
+			}
+			indexed[column.name] = true
+		}
+	}
+
+	var best watermarkChoice
+	bestScore := 0
+	for _, column := range table.columns {
+		name := column.name.String
+		score, ok := watermarkTypes[column.dataType.String]
+		if !ok {
+			continue
+		}
+		reasons := []string{column.dataType.String}
+		if isRowversionType(column.dataType.String) {
+			reasons[0] = "rowversion"
+		} else if !modifiedName.MatchString(name) {
+			continue
+		} else {
+			score += 3
+			reasons = append(reasons, "modified name")
+		}
+
+		switch {
+			case leading[name]:
+				score += 2
+				reasons = append(reasons, "leading index key")
+			case indexed[name]:
+				score++
+				reasons = append(reasons, "index key")
+		}
+		if column.nullable.String == "false" {
+			score++
+			reasons = append(reasons, "not null")
+		}
+
+		if score > bestScore {
+			bestScore = score
+			best = watermarkChoice{column: name, strategy: "auto", reason: strings.Join(reasons, ", ")}
+		}
+	}
+	return best, bestScore > 0
+}
+
+// Whether a type is a rowversion, which sys.columns calls timestamp.
+func isRowversionType(dataType string) bool {
+	return dataType == "timestamp" || dataType == "rowversion"
+}
+
+// Whether the watermark of a table is a rowversion rather than a datetime.
+func rowversionWatermark(table Table) bool {
+	for _, column := range table.columns {
+		if table.timestamp != "" && column.name.String == table.timestamp {
+			return isRowversionType(column.dataType.String)
+		}
+	}
+	return false
+}
+
+// The delta a table carries on from, empty for a full load. The previous delta is only used when it was taken from the same column.
+func deltaSince(table Table, deltaMap map[string]time.Time, deltaColumns map[string]string, deltaVersions map[string]string) string {
+	switch {
+		case table.timestamp == "" || table.type2 || !sameName(deltaColumns[table.name], table.timestamp):
+			return ""
+		case rowversionWatermark(table):
+			return deltaVersions[table.name]
+		case deltaMap[table.name].IsZero():
+			return ""
+		default:
+			return deltaMap[table.name].Format(time.RFC3339)
+	}
+}
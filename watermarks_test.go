@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestChooseWatermark(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		columns: []Column{
+			testColumn("OPEN_TIME", "datetime", "8", "23", "3", "true"),
+			testColumn("UPDATE_TIME", "datetime", "8", "23", "3", "true"),
+			testColumn("SysModTime", "datetime", "8", "23", "3", "true"),
+		},
+	}
+
+	// The list order wins over the column order.
+	choice := chooseWatermark(table, []string{"SYSMODTIME", "UPDATE_TIME"}, "", false)
+	if choice.column != "SysModTime" || choice.strategy != "priority" || choice.reason != "timestamps entry 1" {
+		t.Fatal("The first listed timestamp should win", choice)
+	}
+	choice = chooseWatermark(table, []string{"MISSING", "update_time"}, "", false)
+	if choice.column != "UPDATE_TIME" || choice.reason != "timestamps entry 2" {
+		t.Fatal("Listed timestamps the table lacks should be skipped", choice)
+	}
+
+	if choice = chooseWatermark(table, []string{"SYSMODTIME"}, "open_time", false); choice.column != "OPEN_TIME" || choice.strategy != "override" {
+		t.Fatal("The table settings should win over the list", choice)
+	}
+	if choice = chooseWatermark(table, []string{"SYSMODTIME"}, "none", true); choice.column != "" || choice.strategy != "override" {
+		t.Fatal("A table set to none should be a full load", choice)
+	}
+	if choice = chooseWatermark(table, []string{"SYSMODTIME"}, "MISSING", false); choice.column != "SysModTime" {
+		t.Fatal("An unknown override should fall back to the list", choice)
+	}
+
+	table.type2 = true
+	if choice = chooseWatermark(table, []string{"SYSMODTIME"}, "", true); choice.column != "" || choice.strategy != "type2" {
+		t.Fatal("Type 2 tables have no watermark", choice)
+	}
+	table.type2 = false
+
+	if choice = chooseWatermark(table, nil, "", false); choice.column != "" || choice.strategy != "none" {
+		t.Fatal("Without a listed timestamp or detection the table is a full load", choice)
+	}
+	if choice = chooseWatermark(table, nil, "auto", false); choice.strategy != "auto" {
+		t.Fatal("The table settings can ask for detection", choice)
+	}
+	if choice = chooseWatermark(table, []string{"OPEN_TIME"}, "auto", false); choice.column == "OPEN_TIME" || choice.strategy != "auto" {
+		t.Fatal("Detection set in the table settings should win over the list", choice)
+	}
+	plain := Table{name: "LOCM1", columns: []Column{testColumn("OPEN_TIME", "datetime", "8", "23", "3", "true")}}
+	if choice = chooseWatermark(plain, []string{"OPEN_TIME"}, "auto", false); choice.column != "OPEN_TIME" || choice.strategy != "priority" {
+		t.Fatal("The list should be used when nothing is detected", choice)
+	}
+}
+
+func TestDetectWatermark(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		columns: []Column{
+			testColumn("OPEN_TIME", "datetime", "8", "23", "3", "false"),
+			testColumn("UPDATE_TIME", "datetime", "8", "23", "3", "true"),
+			testColumn("SYSMODTIME", "datetime2", "8", "27", "7", "false"),
+			testColumn("NUMBER", "varchar", "60", "0", "0", "false"),
+		},
+		indexes: []Index{{name: "INCIDENTSM1_MOD", keyColumns: []IndexColumn{{name: "SYSMODTIME"}}}},
+	}
+
+	choice, ok := detectWatermark(table)
+	if !ok || choice.column != "SYSMODTIME" || choice.strategy != "auto" || choice.reason != "datetime2, modified name, leading index key, not null" {
+		t.Fatal("The indexed modified time should be detected", choice)
+	}
+
+	table.columns = append(table.columns, testColumn("ROW_VERSION", "timestamp", "8", "0", "0", "false"))
+	if choice, ok = detectWatermark(table); !ok || choice.column != "ROW_VERSION" {
+		t.Fatal("A rowversion should win", choice)
+	}
+	table.timestamp = choice.column
+	if !rowversionWatermark(table) {
+		t.Fatal("The watermark should be a rowversion")
+	}
+
+	// Creation times only catch new rows, so they are never picked.
+	table.columns = table.columns[:1]
+	if choice, ok = detectWatermark(table); ok {
+		t.Fatal("No watermark should be detected", choice)
+	}
+}
+
+func TestRowversionDelta(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		timestamp: "ROW_VERSION",
+		where: "0x00000000000007D1",
+		columns: []Column{
+			testColumn("NUMBER", "varchar", "60", "0", "0", "false"),
+			testColumn("ROW_VERSION", "timestamp", "8", "0", "0", "false"),
+		},
+	}
+
+	queryString, parameters := extractQuery(table)
+	if queryString != "SELECT [NUMBER],[ROW_VERSION] FROM [INCIDENTSM1] WHERE [ROW_VERSION] > @since" {
+		t.Fatal("Unexpected rowversion delta", queryString)
+	}
+	if len(parameters) != 1 || !bytes.Equal(parameters[0].(sql.NamedArg).Value.([]byte), []byte{0, 0, 0, 0, 0, 0, 7, 0xD1}) {
+		t.Fatal("The rowversion should be sent as binary", parameters)
+	}
+
+	observer := newWatermarkObserver(table)
+	observer.observe([]interface{}{"IM1", []byte{0, 0, 0, 0, 0, 0, 8, 0}}, []string{"IM1", ""})
+	observer.observe([]interface{}{"IM2", []byte{0, 0, 0, 0, 0, 0, 7, 0xD2}}, []string{"IM2", ""})
+	var entry ManifestEntry
+	observer.finish(&entry)
+	if entry.Watermark == nil || entry.Watermark.Min != "0x00000000000007D2" || entry.Watermark.Max != "0x0000000000000800" {
+		t.Fatal("Unexpected rowversion range", entry.Watermark)
+	}
+}
+
+func TestDeltaSince(t *testing.T) {
+	table := Table{
+		name: "INCIDENTSM1",
+		timestamp: "SYSMODTIME",
+		columns: []Column{
+			testColumn("SYSMODTIME", "datetime", "8", "23", "3", "true"),
+			testColumn("ROW_VERSION", "timestamp", "8", "0", "0", "false"),
+		},
+	}
+	since := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	deltaMap := map[string]time.Time{"INCIDENTSM1": since}
+	deltaColumns := map[string]string{"INCIDENTSM1": "SYSMODTIME"}
+	deltaVersions := map[string]string{"INCIDENTSM1": "0x00000000000007D1"}
+
+	if where := deltaSince(table, deltaMap, deltaColumns, deltaVersions); where != since.Format(time.RFC3339) {
+		t.Fatal("The previous delta should be carried on", where)
+	}
+	if where := deltaSince(table, deltaMap, map[string]string{"INCIDENTSM1": "UPDATE_TIME"}, deltaVersions); where != "" {
+		t.Fatal("A delta taken from another column should be a full load", where)
+	}
+
+	table.timestamp = "ROW_VERSION"
+	deltaColumns["INCIDENTSM1"] = "ROW_VERSION"
+	if where := deltaSince(table, deltaMap, deltaColumns, deltaVersions); where != "0x00000000000007D1" {
+		t.Fatal("A rowversion watermark should carry on from the version", where)
+	}
+
+	table.type2 = true
+	if where := deltaSince(table, deltaMap, deltaColumns, deltaVersions); where != "" {
+		t.Fatal("Type 2 tables should be a full load", where)
+	}
+	table.type2 = false
+	table.timestamp = ""
+	if where := deltaSince(table, deltaMap, deltaColumns, deltaVersions); where != "" {
+		t.Fatal("Tables without a watermark should be a full load", where)
+	}
+}